@@ -0,0 +1,34 @@
+package metrics
+
+import (
+	"errors"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Register 将 c 注册到 reg，reg 为空时使用 prometheus.DefaultRegisterer，
+// 已注册过相同的指标时返回已存在的指标，其它错误（如同名指标的 label 不一致）原样返回
+func Register[T prometheus.Collector](reg prometheus.Registerer, c T) (T, error) {
+	if reg == nil {
+		reg = prometheus.DefaultRegisterer
+	}
+	if err := reg.Register(c); err != nil {
+		var are prometheus.AlreadyRegisteredError
+		if errors.As(err, &are) {
+			if existing, ok := are.ExistingCollector.(T); ok {
+				return existing, nil
+			}
+		}
+		return c, err
+	}
+	return c, nil
+}
+
+// MustRegister 同 Register，注册失败时 panic
+func MustRegister[T prometheus.Collector](reg prometheus.Registerer, c T) T {
+	c, err := Register(reg, c)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
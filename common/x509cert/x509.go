@@ -14,6 +14,15 @@ import (
 )
 
 func Generate(expTime time.Duration, ip []string) (certbuf *bytes.Buffer, keybuf *bytes.Buffer, err error) {
+	return generate(expTime, ip, x509.ExtKeyUsageServerAuth)
+}
+
+// GenerateClient 生成同时可用于 mTLS 客户端认证的自签名证书，其它与 Generate 相同
+func GenerateClient(expTime time.Duration, ip []string) (certbuf *bytes.Buffer, keybuf *bytes.Buffer, err error) {
+	return generate(expTime, ip, x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth)
+}
+
+func generate(expTime time.Duration, ip []string, extKeyUsage ...x509.ExtKeyUsage) (certbuf *bytes.Buffer, keybuf *bytes.Buffer, err error) {
 	max := new(big.Int).Lsh(big.NewInt(1), 128)
 	serialNum, _ := rand.Int(rand.Reader, max)
 
@@ -23,7 +32,7 @@ func Generate(expTime time.Duration, ip []string) (certbuf *bytes.Buffer, keybuf
 		NotBefore:    time.Now(),
 		NotAfter:     time.Now().Add(expTime),
 		KeyUsage:    x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
-		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		ExtKeyUsage: extKeyUsage,
 		IPAddresses: []net.IP{net.ParseIP("127.0.0.1")},
 	}
 	if len(ip) > 0 {
@@ -0,0 +1,143 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+var (
+	defaultAuditMaxBodySize     = 4096
+	defaultAuditSensitiveFields = []string{"password", "passwd", "token", "secret", "authorization"}
+)
+
+// AuditRecord 一次请求的审计记录，可直接作为 GORM 模型写入 audit_records 表
+type AuditRecord struct {
+	ID           uint64        `json:"-" gorm:"primaryKey;autoIncrement"`
+	RequestID    string        `json:"requestId" gorm:"size:64;index"`
+	Method       string        `json:"method" gorm:"size:16"`
+	Path         string        `json:"path" gorm:"size:255;index"`
+	Query        string        `json:"query" gorm:"size:2048"`
+	ClientIP     string        `json:"clientIp" gorm:"size:64"`
+	RequestBody  string        `json:"requestBody" gorm:"type:text"`
+	ResponseBody string        `json:"responseBody" gorm:"type:text"`
+	Status       int           `json:"status"`
+	Latency      time.Duration `json:"latency"`
+	CreatedAt    time.Time     `json:"createdAt" gorm:"index"`
+}
+
+func (AuditRecord) TableName() string {
+	return "audit_records"
+}
+
+// AuditSink 审计记录的输出端
+type AuditSink interface {
+	Write(ctx context.Context, record *AuditRecord) error
+}
+
+// AuditSinkFunc 函数形式的 AuditSink
+type AuditSinkFunc func(ctx context.Context, record *AuditRecord) error
+
+func (f AuditSinkFunc) Write(ctx context.Context, record *AuditRecord) error {
+	return f(ctx, record)
+}
+
+// NewLoggerAuditSink 将审计记录输出到日志
+func NewLoggerAuditSink(log *zap.Logger) AuditSink {
+	return AuditSinkFunc(func(ctx context.Context, r *AuditRecord) error {
+		log.Info("core audit: ",
+			zap.String("requestId", r.RequestID),
+			zap.String("method", r.Method),
+			zap.String("path", r.Path),
+			zap.String("query", r.Query),
+			zap.String("ip", r.ClientIP),
+			zap.String("request", r.RequestBody),
+			zap.String("response", r.ResponseBody),
+			zap.Int("status", r.Status),
+			zap.Duration("latency", r.Latency),
+		)
+		return nil
+	})
+}
+
+// AuditDB 审计记录的存储，sqldb.DB 满足该接口
+type AuditDB interface {
+	Create(ctx context.Context, value interface{}) error
+}
+
+// NewDBAuditSink 将审计记录写入数据库，表结构可通过 AutoMigrate(&AuditRecord{}) 创建。
+// 写库是同步的，高并发场景可配合 NewChanAuditSink 异步消费。
+func NewDBAuditSink(db AuditDB) AuditSink {
+	return AuditSinkFunc(func(ctx context.Context, r *AuditRecord) error {
+		return db.Create(ctx, r)
+	})
+}
+
+// NewChanAuditSink 将审计记录发送到 ch，ch 已满时丢弃记录并返回错误，不会阻塞请求
+func NewChanAuditSink(ch chan<- *AuditRecord) AuditSink {
+	return AuditSinkFunc(func(ctx context.Context, r *AuditRecord) error {
+		select {
+		case ch <- r:
+			return nil
+		default:
+			return errors.New("audit channel is full")
+		}
+	})
+}
+
+// sensitiveMasker 将 json 及表单中敏感字段的值替换为 ***
+type sensitiveMasker struct {
+	jsonString *regexp.Regexp
+	jsonValue  *regexp.Regexp
+	form       *regexp.Regexp
+}
+
+func newSensitiveMasker(fields []string) *sensitiveMasker {
+	if len(fields) == 0 {
+		return nil
+	}
+
+	quoted := make([]string, 0, len(fields))
+	for _, f := range fields {
+		quoted = append(quoted, regexp.QuoteMeta(f))
+	}
+	names := "(?i)(" + strings.Join(quoted, "|") + ")"
+
+	return &sensitiveMasker{
+		jsonString: regexp.MustCompile(`"` + names + `"(\s*:\s*)"(?:[^"\\]|\\.)*"?`),
+		jsonValue:  regexp.MustCompile(`"` + names + `"(\s*:\s*)([^"\s,{}\[\]][^,}\]]*)`),
+		form:       regexp.MustCompile(`(^|[?&])` + names + `=[^&]*`),
+	}
+}
+
+func (m *sensitiveMasker) mask(s string) string {
+	if m == nil || s == "" {
+		return s
+	}
+	if json.Valid([]byte(s)) || strings.HasPrefix(strings.TrimSpace(s), "{") {
+		s = m.jsonString.ReplaceAllString(s, `"$1"$2"***"`)
+		return m.jsonValue.ReplaceAllString(s, `"$1"$2"***"`)
+	}
+	return m.form.ReplaceAllString(s, "$1$2=***")
+}
+
+// maskBody 先按原始长度截断再脱敏，脱敏后长度变化不影响截断标记
+func (m *sensitiveMasker) maskBody(body string, max int) string {
+	if max > 0 && len(body) > max {
+		return m.mask(body[:max]) + "...(truncated)"
+	}
+	return m.mask(body)
+}
+
+func (m *mux) writeAudit(ctx context.Context, record *AuditRecord) {
+	for _, sink := range m.opt.auditSinks {
+		if err := sink.Write(ctx, record); err != nil {
+			m.logError("core:write audit record error", err)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package core
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestSensitiveMasker(t *testing.T) {
+	masker := newSensitiveMasker(defaultAuditSensitiveFields)
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"json string", `{"user":"bob","password":"p\"w"}`, `{"user":"bob","password":"***"}`},
+		{"json number", `{"token": 12345, "id": 1}`, `{"token": "***", "id": 1}`},
+		{"case insensitive", `{"Secret":"s","nested":{"ACCESS":"a","Authorization":"Bearer x"}}`,
+			`{"Secret":"***","nested":{"ACCESS":"a","Authorization":"***"}}`},
+		{"truncated json", `{"name":"a","password":"abc`, `{"name":"a","password":"***"`},
+		{"form", "user=bob&password=123&token=abc", "user=bob&password=***&token=***"},
+		{"query prefix", "?passwd=1&x=2", "?passwd=***&x=2"},
+		{"no match", "user=bob&tokens_count=1", "user=bob&tokens_count=1"},
+		{"empty", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := masker.mask(tt.in); got != tt.want {
+				t.Errorf("mask(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+
+	if newSensitiveMasker(nil).mask("password=1") != "password=1" {
+		t.Error("expected nil masker to keep input")
+	}
+}
+
+func TestAudit(t *testing.T) {
+	var records []*AuditRecord
+	sink := AuditSinkFunc(func(ctx context.Context, r *AuditRecord) error {
+		records = append(records, r)
+		return nil
+	})
+	m := newTestMux(t, WithAudit(sink), WithAuditMaxBodySize(32))
+
+	var received string
+	m.Group("/").POST("/login", func(c *gin.Context) {
+		body, _ := io.ReadAll(c.Request.Body)
+		received = string(body)
+		c.String(http.StatusCreated, `{"token":"abc","msg":"`+strings.Repeat("x", 64)+`"}`)
+	})
+
+	reqBody := `{"user":"bob","password":"123456","remark":"` + strings.Repeat("r", 64) + `"}`
+	req := httptest.NewRequest(http.MethodPost, "/login?token=q&page=1", strings.NewReader(reqBody))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("requestId", "req-1")
+	w := httptest.NewRecorder()
+	m.ServeHTTP(w, req)
+
+	// 审计只读取部分 body，处理函数仍能读到完整内容
+	if received != reqBody {
+		t.Fatalf("handler received truncated body %q", received)
+	}
+	if w.Code != http.StatusCreated || !strings.Contains(w.Body.String(), `"token":"abc"`) {
+		t.Fatalf("response should not be masked: %d %s", w.Code, w.Body.String())
+	}
+
+	if len(records) != 1 {
+		t.Fatalf("expected 1 record, got %d", len(records))
+	}
+	r := records[0]
+	if r.RequestID != "req-1" || r.Method != http.MethodPost || r.Path != "/login" || r.Status != http.StatusCreated {
+		t.Fatalf("unexpected record %+v", r)
+	}
+	if r.Query != "token=***&page=1" {
+		t.Errorf("expected masked query, got %q", r.Query)
+	}
+	if !strings.HasPrefix(r.RequestBody, `{"user":"bob","password":"***"`) || !strings.HasSuffix(r.RequestBody, "...(truncated)") {
+		t.Errorf("expected masked and truncated request body, got %q", r.RequestBody)
+	}
+	if !strings.HasPrefix(r.ResponseBody, `{"token":"***"`) || !strings.HasSuffix(r.ResponseBody, "...(truncated)") {
+		t.Errorf("expected masked and truncated response body, got %q", r.ResponseBody)
+	}
+	if strings.Contains(r.RequestBody, "123456") || strings.Contains(r.RequestBody, "remark") {
+		t.Errorf("expected body truncated to 32 bytes before masking, got %q", r.RequestBody)
+	}
+
+	// withoutTracePaths 中的路径不记录
+	m.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if len(records) != 1 {
+		t.Fatalf("expected /healthz to be skipped, got %d records", len(records))
+	}
+}
+
+func TestAuditWithoutBody(t *testing.T) {
+	for _, size := range []int{0, -1} {
+		var records []*AuditRecord
+		m := newTestMux(t, WithAudit(AuditSinkFunc(func(ctx context.Context, r *AuditRecord) error {
+			records = append(records, r)
+			return nil
+		})), WithAuditMaxBodySize(size))
+
+		var received string
+		m.Group("/").POST("/echo", func(c *gin.Context) {
+			body, _ := io.ReadAll(c.Request.Body)
+			received = string(body)
+			c.String(http.StatusOK, "pong")
+		})
+
+		w := httptest.NewRecorder()
+		m.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/echo", strings.NewReader("ping")))
+		if received != "ping" || w.Body.String() != "pong" {
+			t.Fatalf("size %d: unexpected exchange %q %q", size, received, w.Body.String())
+		}
+		// <=0 时不记录 body，其它字段照常记录
+		if len(records) != 1 || records[0].RequestBody != "" || records[0].ResponseBody != "" || records[0].Status != http.StatusOK {
+			t.Fatalf("size %d: expected record without bodies, got %+v", size, records)
+		}
+	}
+}
+
+func TestAuditMultipart(t *testing.T) {
+	var record *AuditRecord
+	m := newTestMux(t, WithAudit(AuditSinkFunc(func(ctx context.Context, r *AuditRecord) error {
+		record = r
+		return nil
+	})))
+	m.Group("/").POST("/upload", func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/upload", strings.NewReader("--x\r\n\r\nfile\r\n--x--\r\n"))
+	req.Header.Set("Content-Type", "multipart/form-data; boundary=x")
+	m.ServeHTTP(httptest.NewRecorder(), req)
+	if record == nil || record.RequestBody != "[multipart body]" {
+		t.Fatalf("expected multipart placeholder, got %+v", record)
+	}
+}
+
+func TestChanAuditSink(t *testing.T) {
+	ch := make(chan *AuditRecord, 1)
+	sink := NewChanAuditSink(ch)
+	if err := sink.Write(context.Background(), &AuditRecord{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Write(context.Background(), &AuditRecord{}); err == nil {
+		t.Fatal("expected error when channel is full")
+	}
+}
@@ -2,20 +2,31 @@ package core
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"os/signal"
 	"runtime/debug"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 
+	"github.com/ffhuo/go-kits/common/shutdown"
+	"github.com/ffhuo/go-kits/ginmiddleware"
+	"github.com/ffhuo/go-kits/ginmiddleware/response"
 	"github.com/ffhuo/go-kits/prometheus"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-contrib/pprof"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	promclient "github.com/prometheus/client_golang/prometheus"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 )
 
@@ -23,13 +34,36 @@ type Option func(*option)
 
 type option struct {
 	name              string
+	apiVersion        string
 	debug             bool
 	enablePProf       bool
 	disableSwagger    bool
 	disablePrometheus bool
+	disableHealth     bool
 	enableCors        bool
+	errorHandler      bool
 	log               *zap.Logger
 	withoutTracePaths map[string]bool
+
+	addr              string
+	readTimeout       time.Duration
+	readHeaderTimeout time.Duration
+	writeTimeout      time.Duration
+	idleTimeout       time.Duration
+	shutdownTimeout   time.Duration
+	shutdownHook      shutdown.Hook
+
+	auditSinks           []AuditSink
+	auditMaxBodySize     int
+	auditSensitiveFields []string
+
+	enableTracing  bool
+	tracerProvider trace.TracerProvider
+
+	compress *ginmiddleware.CompressConfig
+
+	metricsRegistry promclient.Registerer
+	runtimeMetrics  bool
 }
 
 func WithName(name string) Option {
@@ -38,6 +72,13 @@ func WithName(name string) Option {
 	}
 }
 
+// WithVersion 设置服务版本，用于生成的 OpenAPI 文档
+func WithVersion(version string) Option {
+	return func(opt *option) {
+		opt.apiVersion = version
+	}
+}
+
 func WithLogger(log *zap.Logger) Option {
 	return func(opt *option) {
 		opt.log = log
@@ -71,6 +112,69 @@ func WithDisablePrometheus() Option {
 	}
 }
 
+// WithMetricsRegistry 指标注册到 reg 而不是 prometheus 默认注册表，/metrics 输出 reg 中的指标，
+// 多个 Mux 或测试中需要相互隔离时使用
+func WithMetricsRegistry(reg promclient.Registerer) Option {
+	return func(opt *option) {
+		opt.metricsRegistry = reg
+	}
+}
+
+// WithRuntimeMetrics 注册 Go 运行时、进程及 build_info 指标，版本信息见 prometheus.Version
+func WithRuntimeMetrics() Option {
+	return func(opt *option) {
+		opt.runtimeMetrics = true
+	}
+}
+
+// WithDisableHealth 不注册 /healthz、/readyz 健康检查接口
+func WithDisableHealth() Option {
+	return func(opt *option) {
+		opt.disableHealth = true
+	}
+}
+
+// WithAudit 开启请求审计，记录请求与响应内容并写入 sinks，withoutTracePaths 中的路径不记录
+func WithAudit(sinks ...AuditSink) Option {
+	return func(opt *option) {
+		opt.auditSinks = append(opt.auditSinks, sinks...)
+	}
+}
+
+// WithAuditMaxBodySize 设置审计记录中请求、响应 body 的最大长度，超出部分截断，默认 4KB，<=0 时不记录 body
+func WithAuditMaxBodySize(size int) Option {
+	return func(opt *option) {
+		opt.auditMaxBodySize = size
+	}
+}
+
+// WithAuditSensitiveFields 设置审计记录中需要脱敏的字段，默认 password、token、secret 等
+func WithAuditSensitiveFields(fields ...string) Option {
+	return func(opt *option) {
+		opt.auditSensitiveFields = fields
+	}
+}
+
+// WithTracing 开启链路追踪，从请求头提取 W3C traceparent 并创建服务端 span，
+// tp 为 nil 时使用 otel 全局 TracerProvider
+func WithTracing(tp trace.TracerProvider) Option {
+	return func(opt *option) {
+		opt.enableTracing = true
+		opt.tracerProvider = tp
+	}
+}
+
+// WithCompression 开启响应压缩及 ETag，config 为 nil 时使用 ginmiddleware.DefaultCompressConfig，
+// 压缩在审计之前注册，审计记录中保存的是未压缩的响应
+func WithCompression(config *ginmiddleware.CompressConfig) Option {
+	return func(opt *option) {
+		if config == nil {
+			config = ginmiddleware.DefaultCompressConfig()
+		}
+		opt.compress = config
+	}
+}
+
 // WithEnableCors 设置支持跨域
 func WithEnableCors() Option {
 	return func(opt *option) {
@@ -78,6 +182,13 @@ func WithEnableCors() Option {
 	}
 }
 
+// WithErrorHandler 处理函数通过 c.Error 返回的错误统一渲染为 {code,msg,requestId}
+func WithErrorHandler() Option {
+	return func(opt *option) {
+		opt.errorHandler = true
+	}
+}
+
 func WithoutTracePaths(paths []string) Option {
 	return func(o *option) {
 		o.withoutTracePaths = make(map[string]bool, len(paths))
@@ -87,12 +198,75 @@ func WithoutTracePaths(paths []string) Option {
 	}
 }
 
+// WithAddr 设置 Run 监听的地址，默认 :8080
+func WithAddr(addr string) Option {
+	return func(opt *option) {
+		opt.addr = addr
+	}
+}
+
+// WithReadTimeout 设置读取整个请求（包括body）的超时时间
+func WithReadTimeout(d time.Duration) Option {
+	return func(opt *option) {
+		opt.readTimeout = d
+	}
+}
+
+// WithReadHeaderTimeout 设置读取请求头的超时时间
+func WithReadHeaderTimeout(d time.Duration) Option {
+	return func(opt *option) {
+		opt.readHeaderTimeout = d
+	}
+}
+
+// WithWriteTimeout 设置写响应的超时时间
+func WithWriteTimeout(d time.Duration) Option {
+	return func(opt *option) {
+		opt.writeTimeout = d
+	}
+}
+
+// WithIdleTimeout 设置 keep-alive 连接的空闲超时时间
+func WithIdleTimeout(d time.Duration) Option {
+	return func(opt *option) {
+		opt.idleTimeout = d
+	}
+}
+
+// WithShutdownTimeout 设置 Run 退出时等待处理中请求完成的最长时间，默认 10s
+func WithShutdownTimeout(d time.Duration) Option {
+	return func(opt *option) {
+		opt.shutdownTimeout = d
+	}
+}
+
+// WithShutdownHook Run 在收到 hook 的信号时优雅退出，未设置时默认监听 SIGINT、SIGTERM
+func WithShutdownHook(hook shutdown.Hook) Option {
+	return func(opt *option) {
+		opt.shutdownHook = hook
+	}
+}
+
 var _ Mux = (*mux)(nil)
 
 // Mux http mux
 type Mux interface {
 	http.Handler
+	// Start 监听 port 并在后台处理请求，监听失败时返回错误
 	Start(port string) error
+	// Run 启动服务并阻塞，直到 ctx 结束、收到退出信号或服务异常退出，退出前等待处理中的请求完成。
+	// 返回后可再次调用；shutdown hook 触发后再调用 Run 会立即退出
+	Run(ctx context.Context) error
+	// Shutdown 停止接收新请求，并等待处理中的请求完成或 ctx 结束
+	Shutdown(ctx context.Context) error
+	// AddHealthChecker 注册名为 name 的组件检查，同名检查会被替换
+	AddHealthChecker(name string, check HealthCheckFunc, opts ...CheckOption)
+	// Router 创建可注册类型化处理函数的路由分组，见 Handle、GET、POST 等
+	Router(relativePath string, handlers ...gin.HandlerFunc) *Router
+	// OpenAPI 根据类型化路由生成 OpenAPI 3 文档，同时通过 /docs/openapi.json 提供
+	OpenAPI() *OpenAPI
+	// Metrics 返回 prometheus 实例，可通过 RegisterCounter、RegisterGauge 等注册业务指标，禁用 prometheus 时返回 nil
+	Metrics() *prometheus.Prometheus
 	Group(relativePath string, handlers ...gin.HandlerFunc) *gin.RouterGroup
 }
 
@@ -101,12 +275,29 @@ type mux struct {
 	disableSwagger bool
 	engine         *gin.Engine
 	log            *zap.Logger
+	opt            *option
+	health         *health
+	routes         *routeTable
+	metrics        *prometheus.Prometheus
+
+	mu      sync.Mutex
+	server  *http.Server
+	serveCh chan error
+
+	// shutdown hook 只注册一次，触发时取消当前的 Run
+	hookOnce  sync.Once
+	hookFired bool
+	runCancel context.CancelFunc
 }
 
 func (m *mux) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	m.engine.ServeHTTP(w, req)
 }
 
+func (m *mux) Metrics() *prometheus.Prometheus {
+	return m.metrics
+}
+
 func (m *mux) Group(relativePath string, handlers ...gin.HandlerFunc) *gin.RouterGroup {
 	return m.engine.Group(relativePath, handlers...)
 }
@@ -114,9 +305,18 @@ func (m *mux) Group(relativePath string, handlers ...gin.HandlerFunc) *gin.Route
 func New(options ...Option) (Mux, error) {
 	mux := &mux{
 		engine: gin.New(),
+		health: &health{},
+		routes: &routeTable{},
+	}
+	// 处理函数中使用 *gin.Context 作为 context.Context 时，继承请求 context 的 deadline 和取消信号
+	mux.engine.ContextWithFallback = true
+
+	opt := &option{
+		addr:                 ":8080",
+		shutdownTimeout:      10 * time.Second,
+		auditMaxBodySize:     defaultAuditMaxBodySize,
+		auditSensitiveFields: defaultAuditSensitiveFields,
 	}
-
-	opt := new(option)
 	for _, f := range options {
 		f(opt)
 	}
@@ -143,8 +343,11 @@ func New(options ...Option) (Mux, error) {
 		"/favicon.ico": true,
 
 		"/healthCheck": true,
+		"/healthz":     true,
+		"/readyz":      true,
 
-		"/docs/index.html": true,
+		"/docs/index.html":   true,
+		"/docs/openapi.json": true,
 	} {
 		opt.withoutTracePaths[k] = v
 	}
@@ -152,11 +355,55 @@ func New(options ...Option) (Mux, error) {
 	mux.debug = opt.debug
 	mux.disableSwagger = opt.disableSwagger
 	mux.log = opt.log
+	mux.opt = opt
+
+	if opt.enableTracing {
+		skipPaths := make([]string, 0, len(opt.withoutTracePaths))
+		for path := range opt.withoutTracePaths {
+			skipPaths = append(skipPaths, path)
+		}
+		mux.engine.Use(ginmiddleware.Tracing(&ginmiddleware.TracingConfig{
+			TracerProvider: opt.tracerProvider,
+			SkipPaths:      skipPaths,
+		}))
+	}
+
+	var metrics *prometheus.Prometheus
+	if !opt.disablePrometheus {
+		configs := []prometheus.Config{prometheus.Logger(opt.log)}
+		if opt.metricsRegistry != nil {
+			configs = append(configs, prometheus.Registry(opt.metricsRegistry))
+		}
+		if opt.runtimeMetrics {
+			configs = append(configs, prometheus.GoCollector(), prometheus.ProcessCollector(), prometheus.BuildInfo())
+		}
+		metrics = prometheus.Init(configs...)
+		mux.metrics = metrics
+	}
+	// panic 计入 prometheus panic_total
+	recovery := gin.CustomRecovery(func(c *gin.Context, err any) {
+		if metrics != nil {
+			metrics.PanicInc()
+		}
+		c.AbortWithStatus(http.StatusInternalServerError)
+	})
 
 	if opt.log != nil {
-		mux.engine.Use(mux.genRequestID(), mux.Logger(opt.log, opt.withoutTracePaths), gin.Recovery())
+		mux.engine.Use(mux.genRequestID(), mux.Logger(opt.log, opt.withoutTracePaths), recovery)
 	} else {
-		mux.engine.Use(mux.genRequestID(), gin.Logger(), gin.Recovery())
+		mux.engine.Use(mux.genRequestID(), gin.Logger(), recovery)
+	}
+
+	if opt.compress != nil {
+		mux.engine.Use(ginmiddleware.Compress(opt.compress))
+	}
+
+	if len(opt.auditSinks) > 0 {
+		mux.engine.Use(mux.traceOPRecord(opt.withoutTracePaths))
+	}
+
+	if opt.errorHandler {
+		mux.engine.Use(response.ErrorHandler())
 	}
 
 	if !opt.debug {
@@ -167,13 +414,32 @@ func New(options ...Option) (Mux, error) {
 		pprof.Register(mux.engine) // register pprof to gin
 	}
 
+	if !opt.disableHealth {
+		mux.engine.GET("/healthz", mux.health.handler(true))
+		mux.engine.GET("/healthCheck", mux.health.handler(true))
+		mux.engine.GET("/readyz", mux.health.handler(false))
+	}
+
 	if !opt.disableSwagger {
-		mux.engine.GET("/docs/*any", ginSwagger.WrapHandler(swaggerFiles.Handler)) // register swagger
+		// 注册了类型化路由时，文档页面展示运行时生成的 /docs/openapi.json，否则展示 swag 生成的文档
+		swagger := ginSwagger.WrapHandler(swaggerFiles.Handler)
+		openapi := ginSwagger.WrapHandler(swaggerFiles.Handler, ginSwagger.URL("openapi.json"))
+		mux.engine.GET("/docs/*any", func(c *gin.Context) {
+			if c.Param("any") == "/openapi.json" {
+				mux.serveOpenAPI(c)
+				return
+			}
+			if len(mux.routes.list()) > 0 {
+				openapi(c)
+				return
+			}
+			swagger(c)
+		}) // register swagger
 	}
 
-	if !opt.disablePrometheus {
-		p := prometheus.Init(prometheus.Logger(opt.log), prometheus.Handler(mux.engine))
-		mux.engine.Use(p.Middleware(opt.withoutTracePaths))
+	if metrics != nil {
+		mux.engine.GET("/metrics", gin.WrapH(metrics.HTTPHandler()))
+		mux.engine.Use(metrics.Middleware(opt.withoutTracePaths))
 	}
 
 	if opt.enableCors {
@@ -196,6 +462,9 @@ func New(options ...Option) (Mux, error) {
 	mux.engine.Use(func(ctx *gin.Context) {
 		defer func() {
 			if err := recover(); err != nil {
+				if metrics != nil {
+					metrics.PanicInc()
+				}
 				fmt.Fprintf(gin.DefaultWriter, "core::got panic: %+v\n, stack: %s", err, string(debug.Stack()))
 			}
 		}()
@@ -237,15 +506,40 @@ func (m *mux) Logger(log *zap.Logger, ignorePaths map[string]bool) gin.HandlerFu
 }
 
 func (m *mux) Start(port string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.server != nil {
+		return errors.New("server already started")
+	}
+
+	// 同步监听，端口被占用等错误直接返回给调用方
+	ln, err := net.Listen("tcp", port)
+	if err != nil {
+		return fmt.Errorf("server listen: %v", err)
+	}
+
 	server := &http.Server{
-		Addr:    port,
-		Handler: m,
+		Addr:              port,
+		Handler:           m,
+		ReadTimeout:       m.opt.readTimeout,
+		ReadHeaderTimeout: m.opt.readHeaderTimeout,
+		WriteTimeout:      m.opt.writeTimeout,
+		IdleTimeout:       m.opt.idleTimeout,
 	}
+	serveCh := make(chan error, 1)
 	go func() {
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			panic(fmt.Errorf("server start: %v", err))
+		err := server.Serve(ln)
+		if err != nil && err != http.ErrServerClosed {
+			m.logError("core:server serve error", err)
+			serveCh <- err
 		}
+		close(serveCh)
 	}()
+	m.server = server
+	m.serveCh = serveCh
+	m.health.shuttingDown.Store(false)
+
 	if m.debug {
 		if !m.disableSwagger {
 			fmt.Fprint(gin.DefaultWriter, "\nstart swagger api: http://localhost"+port+"/docs/index.html\n\n")
@@ -255,36 +549,151 @@ func (m *mux) Start(port string) error {
 	return nil
 }
 
+func (m *mux) Run(ctx context.Context) error {
+	m.mu.Lock()
+	started := m.server != nil
+	serveCh := m.serveCh
+	m.mu.Unlock()
+	if !started {
+		if err := m.Start(m.opt.addr); err != nil {
+			return err
+		}
+		m.mu.Lock()
+		serveCh = m.serveCh
+		m.mu.Unlock()
+	}
+
+	var cancel context.CancelFunc
+	if m.opt.shutdownHook != nil {
+		// hook.Close 阻塞到收到信号且无法取消，因此只启动一个协程，由它取消当前的 Run
+		ctx, cancel = context.WithCancel(ctx)
+		m.mu.Lock()
+		if m.hookFired {
+			cancel()
+		}
+		m.runCancel = cancel
+		m.mu.Unlock()
+		defer func() {
+			m.mu.Lock()
+			m.runCancel = nil
+			m.mu.Unlock()
+		}()
+		m.hookOnce.Do(func() { go m.opt.shutdownHook.Close(m.cancelRun) })
+	} else {
+		// 未设置 hook 时与 shutdown.NewHook 一致监听 SIGINT、SIGTERM，返回后恢复默认的信号处理
+		ctx, cancel = signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
+	}
+	defer cancel()
+
+	var serveErr error
+	select {
+	case <-ctx.Done():
+	case serveErr = <-serveCh:
+	}
+
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), m.opt.shutdownTimeout)
+	defer shutdownCancel()
+	if err := m.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+	return serveErr
+}
+
+func (m *mux) Shutdown(ctx context.Context) error {
+	m.mu.Lock()
+	server := m.server
+	m.mu.Unlock()
+	if server == nil {
+		return nil
+	}
+
+	// 先标记为未就绪，/readyz 返回 503
+	m.health.shuttingDown.Store(true)
+	err := server.Shutdown(ctx)
+	// 监听已关闭，清理后可再次 Start
+	m.mu.Lock()
+	if m.server == server {
+		m.server = nil
+		m.serveCh = nil
+	}
+	m.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("server shutdown: %v", err)
+	}
+	return nil
+}
+
+// cancelRun 由 shutdown hook 调用，取消当前的 Run，之后的 Run 立即退出
+func (m *mux) cancelRun() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.hookFired = true
+	if m.runCancel != nil {
+		m.runCancel()
+	}
+}
+
+func (m *mux) logError(msg string, err error) {
+	if m.log != nil {
+		m.log.Error(msg, zap.Error(err))
+	} else {
+		fmt.Fprintf(gin.DefaultWriter, "%s: %v\n", msg, err)
+	}
+}
+
 func (m *mux) traceOPRecord(withoutTracePaths map[string]bool) gin.HandlerFunc {
+	masker := newSensitiveMasker(m.opt.auditSensitiveFields)
+	maxBodySize := m.opt.auditMaxBodySize
+
 	return func(c *gin.Context) {
 		if withoutTracePaths[c.Request.URL.Path] {
 			c.Next()
 			return
 		}
+		start := time.Now()
+
 		var body []byte
-		if c.Request.Method != http.MethodGet {
-			var err error
-			body, err = io.ReadAll(c.Request.Body)
-			if err != nil {
-				if m.log != nil {
-					m.log.Error("core:read body from request error", zap.Error(err))
-				} else {
-					fmt.Fprintf(gin.DefaultWriter, "core:read body from request error: %v\n", err)
-				}
+		if maxBodySize > 0 && c.Request.Method != http.MethodGet && c.Request.Body != nil {
+			if strings.HasPrefix(c.ContentType(), "multipart/") {
+				body = []byte("[multipart body]")
 			} else {
-				c.Request.Body = io.NopCloser(bytes.NewBuffer(body))
+				// 只读取 maxBodySize+1 字节用于记录，剩余部分原样交给后续处理
+				var err error
+				body, err = io.ReadAll(io.LimitReader(c.Request.Body, int64(maxBodySize)+1))
+				if err != nil {
+					m.logError("core:read body from request error", err)
+				}
+				c.Request.Body = readCloser{
+					Reader: io.MultiReader(bytes.NewReader(body), c.Request.Body),
+					Closer: c.Request.Body,
+				}
 			}
-		} else {
-			body = []byte(c.Request.URL.RequestURI())
 		}
 
 		writer := responseBodyWriter{
 			ResponseWriter: c.Writer,
 			body:           &bytes.Buffer{},
+			limit:          maxBodySize + 1,
+		}
+		if maxBodySize > 0 {
+			c.Writer = writer
 		}
-		c.Writer = writer
 
 		c.Next()
+
+		record := &AuditRecord{
+			RequestID:    c.GetString("requestId"),
+			Method:       c.Request.Method,
+			Path:         c.Request.URL.Path,
+			Query:        masker.mask(c.Request.URL.RawQuery),
+			ClientIP:     c.ClientIP(),
+			RequestBody:  masker.maskBody(string(body), maxBodySize),
+			ResponseBody: masker.maskBody(writer.body.String(), maxBodySize),
+			Status:       c.Writer.Status(),
+			Latency:      time.Since(start),
+			CreatedAt:    start,
+		}
+		m.writeAudit(c.Request.Context(), record)
 	}
 }
 
@@ -303,10 +712,34 @@ func (m *mux) genRequestID() gin.HandlerFunc {
 
 type responseBodyWriter struct {
 	gin.ResponseWriter
-	body *bytes.Buffer
+	body  *bytes.Buffer
+	limit int // 最多缓存的字节数，<=0 表示不限制
 }
 
 func (r responseBodyWriter) Write(b []byte) (int, error) {
-	r.body.Write(b)
+	r.capture(b)
 	return r.ResponseWriter.Write(b)
 }
+
+func (r responseBodyWriter) WriteString(s string) (int, error) {
+	r.capture([]byte(s))
+	return r.ResponseWriter.WriteString(s)
+}
+
+func (r responseBodyWriter) capture(b []byte) {
+	if r.limit <= 0 {
+		r.body.Write(b)
+		return
+	}
+	if remain := r.limit - r.body.Len(); remain > 0 {
+		if len(b) > remain {
+			b = b[:remain]
+		}
+		r.body.Write(b)
+	}
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
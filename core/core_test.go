@@ -0,0 +1,254 @@
+package core
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/ffhuo/go-kits/common/errno"
+	"github.com/ffhuo/go-kits/common/shutdown"
+	"github.com/gin-gonic/gin"
+)
+
+func newTestMux(t *testing.T, opts ...Option) Mux {
+	t.Helper()
+	m, err := New(append([]Option{WithDisablePrometheus(), WithDisableSwagger()}, opts...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+// freeAddr 返回一个当前空闲的本地地址
+func freeAddr(t *testing.T) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	return l.Addr().String()
+}
+
+// fakeHook 调用 trigger 时执行 Close 注册的函数
+type fakeHook struct {
+	ch    chan struct{}
+	calls atomic.Int32
+}
+
+func (h *fakeHook) WithSignals(signals ...syscall.Signal) shutdown.Hook {
+	return h
+}
+
+func (h *fakeHook) Close(funcs ...func()) {
+	h.calls.Add(1)
+	<-h.ch
+	for _, f := range funcs {
+		f()
+	}
+}
+
+func (h *fakeHook) trigger() {
+	close(h.ch)
+}
+
+func TestStart(t *testing.T) {
+	m := newTestMux(t)
+	addr := freeAddr(t)
+	if err := m.Start(addr); err != nil {
+		t.Fatal(err)
+	}
+	defer m.Shutdown(context.Background())
+
+	if err := m.Start(addr); err == nil || !strings.Contains(err.Error(), "already started") {
+		t.Fatalf("expected already started error, got %v", err)
+	}
+
+	// 端口被占用时同步返回错误
+	if err := newTestMux(t).Start(addr); err == nil || !strings.Contains(err.Error(), "listen") {
+		t.Fatalf("expected listen error, got %v", err)
+	}
+
+	resp, err := http.Get("http://" + addr + "/healthz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+}
+
+func TestRunWaitsForInflightRequests(t *testing.T) {
+	addr := freeAddr(t)
+	m := newTestMux(t, WithAddr(addr), WithShutdownTimeout(time.Second))
+
+	started := make(chan struct{})
+	m.Group("/").GET("/slow", func(c *gin.Context) {
+		close(started)
+		time.Sleep(200 * time.Millisecond)
+		c.String(http.StatusOK, "done")
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	runErr := make(chan error, 1)
+	go func() { runErr <- m.Run(ctx) }()
+
+	for i := 0; i < 50; i++ {
+		if conn, err := net.Dial("tcp", addr); err == nil {
+			conn.Close()
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	type result struct {
+		body string
+		err  error
+	}
+	reqCh := make(chan result, 1)
+	go func() {
+		resp, err := http.Get("http://" + addr + "/slow")
+		if err != nil {
+			reqCh <- result{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		reqCh <- result{string(body), err}
+	}()
+
+	<-started
+	cancel()
+	select {
+	case err := <-runErr:
+		t.Fatalf("Run returned before in-flight request finished: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if res := <-reqCh; res.err != nil || res.body != "done" {
+		t.Fatalf("expected in-flight request to complete, got %q %v", res.body, res.err)
+	}
+	if err := <-runErr; err != nil {
+		t.Fatalf("expected clean shutdown, got %v", err)
+	}
+
+	// 退出后不再接收新连接
+	if _, err := net.Dial("tcp", addr); err == nil {
+		t.Fatal("expected listener to be closed")
+	}
+}
+
+func TestRunShutdownHook(t *testing.T) {
+	hook := &fakeHook{ch: make(chan struct{})}
+	m := newTestMux(t, WithShutdownHook(hook))
+	addr := freeAddr(t)
+	if err := m.Start(addr); err != nil {
+		t.Fatal(err)
+	}
+
+	runErr := make(chan error, 1)
+	go func() { runErr <- m.Run(context.Background()) }()
+
+	hook.trigger()
+	select {
+	case err := <-runErr:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Run did not return after shutdown hook")
+	}
+}
+
+func TestRunRestart(t *testing.T) {
+	hook := &fakeHook{ch: make(chan struct{})}
+	addr := freeAddr(t)
+	m := newTestMux(t, WithAddr(addr), WithShutdownHook(hook))
+
+	// ctx 结束后可再次 Run，shutdown hook 不会重复注册
+	for i := 0; i < 2; i++ {
+		ctx, cancel := context.WithCancel(context.Background())
+		runErr := make(chan error, 1)
+		go func() { runErr <- m.Run(ctx) }()
+
+		var resp *http.Response
+		var err error
+		for j := 0; j < 50; j++ {
+			if resp, err = http.Get("http://" + addr + "/healthz"); err == nil {
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		if err != nil {
+			t.Fatalf("run %d: %v", i, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("run %d: expected 200, got %d", i, resp.StatusCode)
+		}
+
+		cancel()
+		if err = <-runErr; err != nil {
+			t.Fatalf("run %d: %v", i, err)
+		}
+	}
+	if n := hook.calls.Load(); n != 1 {
+		t.Fatalf("expected hook to be registered once, got %d", n)
+	}
+
+	// hook 触发后 Run 立即退出
+	hook.trigger()
+	runErr := make(chan error, 1)
+	go func() { runErr <- m.Run(context.Background()) }()
+	select {
+	case err := <-runErr:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Run did not return after shutdown hook fired")
+	}
+}
+
+func TestShutdownBeforeStart(t *testing.T) {
+	if err := newTestMux(t).Shutdown(context.Background()); err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+}
+
+func TestWithErrorHandler(t *testing.T) {
+	handler := func(c *gin.Context) {
+		_ = c.Error(errno.New(http.StatusConflict).SetErrMsg("conflict"))
+	}
+
+	tests := []struct {
+		name   string
+		opts   []Option
+		status int
+		body   string
+	}{
+		{"disabled", nil, http.StatusOK, ""},
+		{"enabled", []Option{WithErrorHandler()}, http.StatusConflict, `{"code":409,"msg":"conflict","requestId":"req-1"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestMux(t, tt.opts...)
+			m.Group("/").GET("/conflict", handler)
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/conflict", nil)
+			req.Header.Set("requestId", "req-1")
+			m.ServeHTTP(w, req)
+			if w.Code != tt.status || w.Body.String() != tt.body {
+				t.Fatalf("expected %d %q, got %d %q", tt.status, tt.body, w.Code, w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,34 @@
+//go:build unix
+
+package core
+
+import (
+	"context"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestRunDefaultSignals(t *testing.T) {
+	m := newTestMux(t)
+	if err := m.Start(freeAddr(t)); err != nil {
+		t.Fatal(err)
+	}
+
+	runErr := make(chan error, 1)
+	go func() { runErr <- m.Run(context.Background()) }()
+
+	// 等待 Run 开始监听信号
+	time.Sleep(50 * time.Millisecond)
+	if err := syscall.Kill(syscall.Getpid(), syscall.SIGTERM); err != nil {
+		t.Skip(err)
+	}
+	select {
+	case err := <-runErr:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Run did not return after SIGTERM")
+	}
+}
@@ -0,0 +1,208 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/ffhuo/go-kits/common/errno"
+	"github.com/ffhuo/go-kits/ginmiddleware/response"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+// HandlerFunc 类型化的处理函数，req 由路径、query、header 和 body 绑定并校验，
+// 返回值按统一响应结构输出，ctx 即当前请求的 *gin.Context
+type HandlerFunc[T, R any] func(ctx context.Context, req *T) (*R, error)
+
+// Router 可注册类型化处理函数的路由分组
+type Router struct {
+	*gin.RouterGroup
+	mux *mux
+}
+
+// Group 创建子分组
+func (r *Router) Group(relativePath string, handlers ...gin.HandlerFunc) *Router {
+	return &Router{
+		RouterGroup: r.RouterGroup.Group(relativePath, handlers...),
+		mux:         r.mux,
+	}
+}
+
+func (m *mux) Router(relativePath string, handlers ...gin.HandlerFunc) *Router {
+	return &Router{
+		RouterGroup: m.engine.Group(relativePath, handlers...),
+		mux:         m,
+	}
+}
+
+// Handle 在 r 上注册类型化处理函数，路由会出现在 /docs/openapi.json 中
+func Handle[T, R any](r *Router, method, path string, fn HandlerFunc[T, R], opts ...RouteOption) {
+	r.RouterGroup.Handle(method, path, Wrap(fn))
+
+	info := &routeInfo{
+		method: method,
+		path:   joinPaths(r.BasePath(), path),
+		req:    reflect.TypeOf((*T)(nil)).Elem(),
+		res:    reflect.TypeOf((*R)(nil)).Elem(),
+	}
+	for _, f := range opts {
+		f(info)
+	}
+	r.mux.routes.add(info)
+}
+
+func GET[T, R any](r *Router, path string, fn HandlerFunc[T, R], opts ...RouteOption) {
+	Handle(r, http.MethodGet, path, fn, opts...)
+}
+
+func POST[T, R any](r *Router, path string, fn HandlerFunc[T, R], opts ...RouteOption) {
+	Handle(r, http.MethodPost, path, fn, opts...)
+}
+
+func PUT[T, R any](r *Router, path string, fn HandlerFunc[T, R], opts ...RouteOption) {
+	Handle(r, http.MethodPut, path, fn, opts...)
+}
+
+func PATCH[T, R any](r *Router, path string, fn HandlerFunc[T, R], opts ...RouteOption) {
+	Handle(r, http.MethodPatch, path, fn, opts...)
+}
+
+func DELETE[T, R any](r *Router, path string, fn HandlerFunc[T, R], opts ...RouteOption) {
+	Handle(r, http.MethodDelete, path, fn, opts...)
+}
+
+// Wrap 将类型化处理函数转换为 gin.HandlerFunc，可用于任意 gin 路由
+func Wrap[T, R any](fn HandlerFunc[T, R]) gin.HandlerFunc {
+	registerValidations()
+	tags, headers := collectTags(reflect.TypeOf((*T)(nil)).Elem())
+
+	return func(c *gin.Context) {
+		req := new(T)
+		if err := bindRequest(c, req, tags, headers); err != nil {
+			response.Fail(c, errno.New(http.StatusBadRequest).SetErrMsg("invalid params: %v", err))
+			return
+		}
+
+		res, err := fn(c, req)
+		if err != nil {
+			response.Fail(c, err)
+			return
+		}
+		if res == nil {
+			response.OK(c, nil)
+			return
+		}
+		response.OK(c, res)
+	}
+}
+
+// bindRequest 依次绑定 query、header、body 和路径参数，最后统一校验
+func bindRequest(c *gin.Context, req interface{}, tags map[string]bool, headers []string) error {
+	if tags["form"] {
+		if err := binding.MapFormWithTag(req, c.Request.URL.Query(), "form"); err != nil {
+			return err
+		}
+	}
+
+	if tags["header"] {
+		// 按 header 标签取值，Header.Values 会规范化大小写，X-Request-ID 与 X-Request-Id 等价
+		header := make(map[string][]string, len(headers))
+		for _, name := range headers {
+			if v := c.Request.Header.Values(name); len(v) > 0 {
+				header[name] = v
+			}
+		}
+		if err := binding.MapFormWithTag(req, header, "header"); err != nil {
+			return err
+		}
+	}
+
+	if err := bindBody(c, req, tags); err != nil {
+		return err
+	}
+
+	if tags["uri"] && len(c.Params) > 0 {
+		params := make(map[string][]string, len(c.Params))
+		for _, p := range c.Params {
+			params[p.Key] = []string{p.Value}
+		}
+		if err := binding.MapFormWithTag(req, params, "uri"); err != nil {
+			return err
+		}
+	}
+
+	if binding.Validator == nil {
+		return nil
+	}
+	return binding.Validator.ValidateStruct(req)
+}
+
+func bindBody(c *gin.Context, req interface{}, tags map[string]bool) error {
+	if c.Request.Body == nil || c.Request.ContentLength == 0 {
+		return nil
+	}
+	switch c.Request.Method {
+	case http.MethodGet, http.MethodHead:
+		return nil
+	}
+
+	switch c.ContentType() {
+	case binding.MIMEPOSTForm:
+		if !tags["form"] {
+			return nil
+		}
+		if err := c.Request.ParseForm(); err != nil {
+			return err
+		}
+		return binding.MapFormWithTag(req, c.Request.PostForm, "form")
+	case binding.MIMEMultipartPOSTForm:
+		if !tags["form"] {
+			return nil
+		}
+		if err := c.Request.ParseMultipartForm(32 << 20); err != nil {
+			return err
+		}
+		return binding.MapFormWithTag(req, c.Request.MultipartForm.Value, "form")
+	default:
+		err := json.NewDecoder(c.Request.Body).Decode(req)
+		if err == io.EOF {
+			return nil
+		}
+		return err
+	}
+}
+
+// collectTags 收集结构体（含匿名嵌入字段）使用到的绑定标签及 header 标签中的请求头名称，只从声明了标签的来源绑定
+func collectTags(t reflect.Type) (map[string]bool, []string) {
+	tags := make(map[string]bool)
+	var headers []string
+	var walk func(t reflect.Type)
+	walk = func(t reflect.Type) {
+		for t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		if t.Kind() != reflect.Struct {
+			return
+		}
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			for _, tag := range []string{"uri", "form", "header", "json"} {
+				if _, ok := f.Tag.Lookup(tag); ok {
+					tags[tag] = true
+				}
+			}
+			if name, _, _ := strings.Cut(f.Tag.Get("header"), ","); name != "" && name != "-" {
+				headers = append(headers, name)
+			}
+			if f.Anonymous {
+				walk(f.Type)
+			}
+		}
+	}
+	walk(t)
+	return tags, headers
+}
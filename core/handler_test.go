@@ -0,0 +1,181 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ffhuo/go-kits/common/errno"
+	"github.com/ffhuo/go-kits/ginmiddleware/response"
+	"github.com/gin-gonic/gin"
+)
+
+type bindPage struct {
+	Page int `form:"page"`
+}
+
+type bindReq struct {
+	bindPage
+	ID    int    `uri:"id" form:"id" json:"id"`
+	Name  string `form:"name" json:"name" binding:"required"`
+	Token string `header:"X-Token"`
+	Email string `json:"email" binding:"omitempty,email_strict"`
+	Phone string `json:"phone" binding:"omitempty,mobile"`
+}
+
+type bindRes struct {
+	ID    int    `json:"id"`
+	Name  string `json:"name"`
+	Token string `json:"token"`
+	Page  int    `json:"page"`
+}
+
+func decodeBody(t *testing.T, w *httptest.ResponseRecorder, data interface{}) response.Body {
+	t.Helper()
+	body := response.Body{Data: data}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("invalid body %q: %v", w.Body.String(), err)
+	}
+	return body
+}
+
+func TestWrapBinding(t *testing.T) {
+	m := newTestMux(t)
+	r := m.Router("/api")
+	PUT(r, "/users/:id", func(ctx context.Context, req *bindReq) (*bindRes, error) {
+		return &bindRes{ID: req.ID, Name: req.Name, Token: req.Token, Page: req.Page}, nil
+	})
+	POST(r, "/users", func(ctx context.Context, req *bindReq) (*bindRes, error) {
+		return &bindRes{ID: req.ID, Name: req.Name, Token: req.Token, Page: req.Page}, nil
+	})
+
+	tests := []struct {
+		name        string
+		method      string
+		url         string
+		contentType string
+		body        string
+		status      int
+		want        bindRes
+		msg         string
+	}{
+		// 依次绑定 query、header、body、路径参数，后绑定的覆盖先绑定的
+		{"path overrides body and query", http.MethodPut, "/api/users/3?id=1&name=q&page=2", "application/json",
+			`{"id":2,"name":"b"}`, http.StatusOK, bindRes{ID: 3, Name: "b", Token: "t", Page: 2}, ""},
+		{"body overrides query", http.MethodPost, "/api/users?id=1&name=q", "application/json",
+			`{"id":2}`, http.StatusOK, bindRes{ID: 2, Name: "q", Token: "t"}, ""},
+		{"form body", http.MethodPost, "/api/users?page=5", "application/x-www-form-urlencoded",
+			"id=4&name=f", http.StatusOK, bindRes{ID: 4, Name: "f", Token: "t", Page: 5}, ""},
+		{"empty body", http.MethodPost, "/api/users?name=q", "application/json",
+			"", http.StatusOK, bindRes{Name: "q", Token: "t"}, ""},
+		{"required", http.MethodPost, "/api/users", "application/json",
+			`{"id":1}`, http.StatusBadRequest, bindRes{}, "invalid params: Key: 'bindReq.Name' Error:Field validation for 'Name' failed on the 'required' tag"},
+		{"email_strict", http.MethodPost, "/api/users", "application/json",
+			`{"name":"a","email":"bad"}`, http.StatusBadRequest, bindRes{}, "email_strict"},
+		{"mobile", http.MethodPost, "/api/users", "application/json",
+			`{"name":"a","phone":"123"}`, http.StatusBadRequest, bindRes{}, "mobile"},
+		{"invalid json", http.MethodPost, "/api/users", "application/json",
+			`{"name":`, http.StatusBadRequest, bindRes{}, "invalid params"},
+		{"invalid path param", http.MethodPut, "/api/users/abc", "application/json",
+			`{"name":"a"}`, http.StatusBadRequest, bindRes{}, "invalid params"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.url, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			req.Header.Set("X-Token", "t")
+			w := httptest.NewRecorder()
+			m.ServeHTTP(w, req)
+
+			if w.Code != tt.status {
+				t.Fatalf("expected %d, got %d: %s", tt.status, w.Code, w.Body.String())
+			}
+			var res bindRes
+			body := decodeBody(t, w, &res)
+			if tt.status != http.StatusOK {
+				if body.Code != http.StatusBadRequest || !strings.Contains(body.Msg, tt.msg) {
+					t.Fatalf("expected message containing %q, got %+v", tt.msg, body)
+				}
+				return
+			}
+			if res != tt.want {
+				t.Fatalf("expected %+v, got %+v", tt.want, res)
+			}
+		})
+	}
+}
+
+func TestWrapHeaderBinding(t *testing.T) {
+	type headerReq struct {
+		RequestID string   `header:"X-Request-ID"`
+		Lower     string   `header:"x-tenant-id"`
+		Tags      []string `header:"X-Tag"`
+		Default   string   `header:"X-Missing,default=none"`
+		Ignored   string   `header:"-"`
+	}
+	m := newTestMux(t)
+	GET(m.Router("/"), "/headers", func(ctx context.Context, req *headerReq) (*headerReq, error) {
+		return req, nil
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/headers", nil)
+	req.Header.Set("X-Request-Id", "r1")
+	req.Header.Set("X-Tenant-Id", "t1")
+	req.Header.Add("x-tag", "a")
+	req.Header.Add("X-TAG", "b")
+	w := httptest.NewRecorder()
+	m.ServeHTTP(w, req)
+
+	var res headerReq
+	decodeBody(t, w, &res)
+	want := headerReq{RequestID: "r1", Lower: "t1", Tags: []string{"a", "b"}, Default: "none"}
+	if w.Code != http.StatusOK || res.RequestID != want.RequestID || res.Lower != want.Lower ||
+		strings.Join(res.Tags, ",") != "a,b" || res.Default != want.Default || res.Ignored != "" {
+		t.Fatalf("expected %+v, got %d %+v", want, w.Code, res)
+	}
+}
+
+func TestWrapErrors(t *testing.T) {
+	m := newTestMux(t)
+	r := m.Router("/")
+	GET(r, "/business", func(ctx context.Context, req *struct{}) (*struct{}, error) {
+		return nil, errno.New(http.StatusNotFound).SetErrMsg("user %d not found", 1)
+	})
+	GET(r, "/internal", func(ctx context.Context, req *struct{}) (*struct{}, error) {
+		return nil, errors.New("dial tcp 10.0.0.1:3306: connection refused")
+	})
+	GET(r, "/nil", func(ctx context.Context, req *struct{}) (*struct{}, error) {
+		if _, ok := ctx.(*gin.Context); !ok {
+			t.Error("expected ctx to be *gin.Context")
+		}
+		return nil, nil
+	})
+
+	tests := []struct {
+		path   string
+		status int
+		code   int
+		msg    string
+	}{
+		{"/business", http.StatusNotFound, http.StatusNotFound, "user 1 not found"},
+		{"/internal", http.StatusInternalServerError, -1, http.StatusText(http.StatusInternalServerError)},
+		{"/nil", http.StatusOK, response.CodeOK, "success"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			req.Header.Set("requestId", "req-1")
+			m.ServeHTTP(w, req)
+
+			body := decodeBody(t, w, nil)
+			if w.Code != tt.status || body.Code != tt.code || body.Msg != tt.msg || body.RequestID != "req-1" {
+				t.Fatalf("expected %d %d %q, got %d %+v", tt.status, tt.code, tt.msg, w.Code, body)
+			}
+		})
+	}
+}
@@ -0,0 +1,197 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	HealthStatusUp   = "up"
+	HealthStatusDown = "down"
+
+	defaultCheckTimeout = 3 * time.Second
+)
+
+// HealthCheckFunc 健康检查函数，返回 nil 表示组件正常，
+// 可直接使用 sqldb.DB.Ping、redis.RedisCli.Ping、mqtt.Client.Ping、influxdb.Client.Ping
+type HealthCheckFunc func(ctx context.Context) error
+
+type CheckOption func(*healthChecker)
+
+// WithCheckTimeout 设置单次检查的超时时间，默认 3s
+func WithCheckTimeout(d time.Duration) CheckOption {
+	return func(hc *healthChecker) {
+		hc.timeout = d
+	}
+}
+
+// WithLiveness 检查项同时参与 /healthz 存活检查，默认只参与 /readyz 就绪检查
+func WithLiveness() CheckOption {
+	return func(hc *healthChecker) {
+		hc.liveness = true
+	}
+}
+
+// ComponentHealth 单个组件的检查结果
+type ComponentHealth struct {
+	Status      string     `json:"status"`
+	Latency     string     `json:"latency"`
+	LastError   string     `json:"lastError,omitempty"`
+	LastErrorAt *time.Time `json:"lastErrorAt,omitempty"`
+}
+
+// HealthReport /healthz、/readyz 的响应内容
+type HealthReport struct {
+	Status     string                     `json:"status"`
+	Components map[string]ComponentHealth `json:"components,omitempty"`
+}
+
+type healthChecker struct {
+	name     string
+	check    HealthCheckFunc
+	timeout  time.Duration
+	liveness bool
+
+	mu          sync.Mutex
+	lastError   string
+	lastErrorAt *time.Time
+}
+
+func (hc *healthChecker) run(ctx context.Context) ComponentHealth {
+	ctx, cancel := context.WithTimeout(ctx, hc.timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := hc.safeCheck(ctx)
+	latency := time.Since(start)
+
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+
+	result := ComponentHealth{
+		Status:  HealthStatusUp,
+		Latency: latency.String(),
+	}
+	if err != nil {
+		now := time.Now()
+		hc.lastError = err.Error()
+		hc.lastErrorAt = &now
+		result.Status = HealthStatusDown
+	}
+	result.LastError = hc.lastError
+	result.LastErrorAt = hc.lastErrorAt
+	return result
+}
+
+func (hc *healthChecker) safeCheck(ctx context.Context) error {
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- fmt.Errorf("health check panic: %v", r)
+			}
+		}()
+		done <- hc.check(ctx)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return errors.New("health check timeout")
+	}
+}
+
+type health struct {
+	mu           sync.RWMutex
+	checkers     []*healthChecker
+	shuttingDown atomic.Bool
+}
+
+func (h *health) add(name string, check HealthCheckFunc, opts ...CheckOption) {
+	hc := &healthChecker{
+		name:    name,
+		check:   check,
+		timeout: defaultCheckTimeout,
+	}
+	for _, f := range opts {
+		f(hc)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i, c := range h.checkers {
+		if c.name == name {
+			h.checkers[i] = hc
+			return
+		}
+	}
+	h.checkers = append(h.checkers, hc)
+	sort.Slice(h.checkers, func(i, j int) bool { return h.checkers[i].name < h.checkers[j].name })
+}
+
+func (h *health) report(ctx context.Context, livenessOnly bool) *HealthReport {
+	h.mu.RLock()
+	checkers := make([]*healthChecker, 0, len(h.checkers))
+	for _, c := range h.checkers {
+		if !livenessOnly || c.liveness {
+			checkers = append(checkers, c)
+		}
+	}
+	h.mu.RUnlock()
+
+	report := &HealthReport{
+		Status:     HealthStatusUp,
+		Components: make(map[string]ComponentHealth, len(checkers)),
+	}
+
+	var (
+		wg sync.WaitGroup
+		mu sync.Mutex
+	)
+	for _, c := range checkers {
+		wg.Add(1)
+		go func(c *healthChecker) {
+			defer wg.Done()
+			result := c.run(ctx)
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Components[c.name] = result
+			if result.Status != HealthStatusUp {
+				report.Status = HealthStatusDown
+			}
+		}(c)
+	}
+	wg.Wait()
+
+	return report
+}
+
+func (h *health) handler(livenessOnly bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		report := h.report(c.Request.Context(), livenessOnly)
+		// 退出过程中不再接收新流量
+		if !livenessOnly && h.shuttingDown.Load() {
+			report.Status = HealthStatusDown
+		}
+
+		status := http.StatusOK
+		if report.Status != HealthStatusUp {
+			status = http.StatusServiceUnavailable
+		}
+		c.JSON(status, report)
+	}
+}
+
+func (m *mux) AddHealthChecker(name string, check HealthCheckFunc, opts ...CheckOption) {
+	m.health.add(name, check, opts...)
+}
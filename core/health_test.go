@@ -0,0 +1,125 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func getHealth(t *testing.T, m Mux, path string) (int, *HealthReport) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	m.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+
+	report := &HealthReport{}
+	if err := json.Unmarshal(w.Body.Bytes(), report); err != nil {
+		t.Fatalf("%s: invalid body %q: %v", path, w.Body.String(), err)
+	}
+	return w.Code, report
+}
+
+func TestHealthCheckers(t *testing.T) {
+	tests := []struct {
+		name      string
+		check     HealthCheckFunc
+		opts      []CheckOption
+		healthz   int
+		readyz    int
+		lastError string
+	}{
+		{"up", func(ctx context.Context) error { return nil }, nil, http.StatusOK, http.StatusOK, ""},
+		{"down", func(ctx context.Context) error { return errors.New("connection refused") }, nil,
+			http.StatusOK, http.StatusServiceUnavailable, "connection refused"},
+		{"liveness down", func(ctx context.Context) error { return errors.New("deadlock") }, []CheckOption{WithLiveness()},
+			http.StatusServiceUnavailable, http.StatusServiceUnavailable, "deadlock"},
+		{"timeout", func(ctx context.Context) error {
+			time.Sleep(time.Second)
+			return nil
+		}, []CheckOption{WithCheckTimeout(20 * time.Millisecond)}, http.StatusOK, http.StatusServiceUnavailable, "health check timeout"},
+		{"panic", func(ctx context.Context) error { panic("boom") }, nil,
+			http.StatusOK, http.StatusServiceUnavailable, "health check panic: boom"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestMux(t)
+			m.AddHealthChecker("db", tt.check, tt.opts...)
+
+			code, _ := getHealth(t, m, "/healthz")
+			if code != tt.healthz {
+				t.Errorf("healthz: expected %d, got %d", tt.healthz, code)
+			}
+
+			start := time.Now()
+			code, report := getHealth(t, m, "/readyz")
+			if time.Since(start) > 500*time.Millisecond {
+				t.Errorf("readyz blocked for %s", time.Since(start))
+			}
+			if code != tt.readyz {
+				t.Errorf("readyz: expected %d, got %d", tt.readyz, code)
+			}
+			db := report.Components["db"]
+			if db.LastError != tt.lastError {
+				t.Errorf("expected last error %q, got %q", tt.lastError, db.LastError)
+			}
+			if (tt.lastError != "") != (db.LastErrorAt != nil) {
+				t.Errorf("unexpected lastErrorAt %v", db.LastErrorAt)
+			}
+		})
+	}
+}
+
+func TestHealthLastError(t *testing.T) {
+	m := newTestMux(t)
+	fail := true
+	m.AddHealthChecker("cache", func(ctx context.Context) error {
+		if fail {
+			return errors.New("timeout")
+		}
+		return nil
+	})
+
+	if code, _ := getHealth(t, m, "/readyz"); code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503, got %d", code)
+	}
+
+	// 恢复后状态为 up，但仍保留最近一次错误
+	fail = false
+	code, report := getHealth(t, m, "/readyz")
+	cache := report.Components["cache"]
+	if code != http.StatusOK || cache.Status != HealthStatusUp || cache.LastError != "timeout" {
+		t.Fatalf("expected recovered component with last error, got %d %+v", code, cache)
+	}
+
+	// 同名检查会被替换
+	m.AddHealthChecker("cache", func(ctx context.Context) error { return nil })
+	if _, report = getHealth(t, m, "/readyz"); report.Components["cache"].LastError != "" {
+		t.Fatalf("expected checker to be replaced, got %+v", report.Components["cache"])
+	}
+}
+
+func TestReadyzOnShutdown(t *testing.T) {
+	m := newTestMux(t)
+	if err := m.Start(freeAddr(t)); err != nil {
+		t.Fatal(err)
+	}
+
+	if code, _ := getHealth(t, m, "/readyz"); code != http.StatusOK {
+		t.Fatalf("expected 200 before shutdown, got %d", code)
+	}
+	if err := m.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	code, report := getHealth(t, m, "/readyz")
+	if code != http.StatusServiceUnavailable || report.Status != HealthStatusDown {
+		t.Fatalf("expected readyz to report down after shutdown, got %d %+v", code, report)
+	}
+	// 存活检查不受影响
+	if code, _ = getHealth(t, m, "/healthz"); code != http.StatusOK {
+		t.Fatalf("expected healthz 200 after shutdown, got %d", code)
+	}
+}
@@ -0,0 +1,375 @@
+package core
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"net/http"
+	"path"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RouteOption 类型化路由的文档选项
+type RouteOption func(*routeInfo)
+
+// Summary 设置接口摘要
+func Summary(summary string) RouteOption {
+	return func(r *routeInfo) {
+		r.summary = summary
+	}
+}
+
+// Description 设置接口描述
+func Description(description string) RouteOption {
+	return func(r *routeInfo) {
+		r.description = description
+	}
+}
+
+// Tags 设置接口分组标签
+func Tags(tags ...string) RouteOption {
+	return func(r *routeInfo) {
+		r.tags = append(r.tags, tags...)
+	}
+}
+
+type routeInfo struct {
+	method      string
+	path        string
+	req         reflect.Type
+	res         reflect.Type
+	summary     string
+	description string
+	tags        []string
+}
+
+type routeTable struct {
+	mu     sync.RWMutex
+	routes []*routeInfo
+}
+
+func (t *routeTable) add(r *routeInfo) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.routes = append(t.routes, r)
+}
+
+func (t *routeTable) list() []*routeInfo {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return append([]*routeInfo(nil), t.routes...)
+}
+
+// OpenAPI 文档结构，只包含生成时用到的字段
+type OpenAPI struct {
+	OpenAPI    string                           `json:"openapi"`
+	Info       OpenAPIInfo                      `json:"info"`
+	Paths      map[string]map[string]*Operation `json:"paths"`
+	Components Components                       `json:"components"`
+}
+
+type OpenAPIInfo struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+type Operation struct {
+	Summary     string               `json:"summary,omitempty"`
+	Description string               `json:"description,omitempty"`
+	Tags        []string             `json:"tags,omitempty"`
+	Parameters  []*Parameter         `json:"parameters,omitempty"`
+	RequestBody *RequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*Response `json:"responses"`
+}
+
+type Parameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required,omitempty"`
+	Schema   *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                  `json:"required,omitempty"`
+	Content  map[string]*MediaType `json:"content"`
+}
+
+type Response struct {
+	Description string                `json:"description"`
+	Content     map[string]*MediaType `json:"content,omitempty"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+type Components struct {
+	Schemas map[string]*Schema `json:"schemas,omitempty"`
+}
+
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+}
+
+// OpenAPI 根据类型化路由生成 OpenAPI 3 文档
+func (m *mux) OpenAPI() *OpenAPI {
+	doc := &OpenAPI{
+		OpenAPI: "3.0.3",
+		Info: OpenAPIInfo{
+			Title:   m.opt.name,
+			Version: m.opt.apiVersion,
+		},
+		Paths: make(map[string]map[string]*Operation),
+	}
+	if doc.Info.Title == "" {
+		doc.Info.Title = "API"
+	}
+
+	g := &schemaGenerator{schemas: make(map[string]*Schema), names: make(map[reflect.Type]string)}
+	for _, r := range m.routes.list() {
+		p := openAPIPath(r.path)
+		if doc.Paths[p] == nil {
+			doc.Paths[p] = make(map[string]*Operation)
+		}
+		doc.Paths[p][strings.ToLower(r.method)] = g.operation(r)
+	}
+	doc.Components.Schemas = g.schemas
+	return doc
+}
+
+func (m *mux) serveOpenAPI(c *gin.Context) {
+	c.JSON(http.StatusOK, m.OpenAPI())
+}
+
+// openAPIPath 将 gin 路径 /users/:id/*file 转为 /users/{id}/{file}
+func openAPIPath(p string) string {
+	parts := strings.Split(p, "/")
+	for i, part := range parts {
+		if strings.HasPrefix(part, ":") || strings.HasPrefix(part, "*") {
+			parts[i] = "{" + part[1:] + "}"
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+func joinPaths(base, relative string) string {
+	if relative == "" {
+		return base
+	}
+	p := path.Join(base, relative)
+	if strings.HasSuffix(relative, "/") && !strings.HasSuffix(p, "/") {
+		p += "/"
+	}
+	return p
+}
+
+type schemaGenerator struct {
+	schemas map[string]*Schema
+	names   map[reflect.Type]string
+}
+
+func (g *schemaGenerator) operation(r *routeInfo) *Operation {
+	op := &Operation{
+		Summary:     r.summary,
+		Description: r.description,
+		Tags:        r.tags,
+		Responses:   make(map[string]*Response),
+	}
+
+	body := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	g.walkFields(r.req, func(f reflect.StructField) {
+		required := isRequired(f)
+		for _, in := range []struct{ tag, in string }{{"uri", "path"}, {"form", "query"}, {"header", "header"}} {
+			name, ok := tagName(f, in.tag)
+			if !ok {
+				continue
+			}
+			op.Parameters = append(op.Parameters, &Parameter{
+				Name:     name,
+				In:       in.in,
+				Required: required || in.in == "path",
+				Schema:   g.schema(f.Type),
+			})
+			return
+		}
+		if name, ok := tagName(f, "json"); ok {
+			body.Properties[name] = g.schema(f.Type)
+			if required {
+				body.Required = append(body.Required, name)
+			}
+		}
+	})
+	if len(body.Properties) > 0 && r.method != http.MethodGet && r.method != http.MethodHead {
+		op.RequestBody = &RequestBody{
+			Required: true,
+			Content:  map[string]*MediaType{"application/json": {Schema: body}},
+		}
+	}
+
+	envelope := &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"code":      {Type: "integer"},
+			"msg":       {Type: "string"},
+			"data":      g.schema(r.res),
+			"requestId": {Type: "string"},
+		},
+		Required: []string{"code", "msg"},
+	}
+	op.Responses["200"] = &Response{
+		Description: "OK",
+		Content:     map[string]*MediaType{"application/json": {Schema: envelope}},
+	}
+	op.Responses["default"] = &Response{Description: "BusinessError"}
+	return op
+}
+
+// walkFields 遍历结构体导出字段，匿名嵌入的结构体字段会被展开
+func (g *schemaGenerator) walkFields(t reflect.Type, fn func(reflect.StructField)) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return
+	}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Anonymous && f.Tag.Get("json") == "" {
+			g.walkFields(f.Type, fn)
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		fn(f)
+	}
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func (g *schemaGenerator) schema(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == timeType {
+		return &Schema{Type: "string", Format: "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: g.schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.schema(t.Elem())}
+	case reflect.Struct:
+		return &Schema{Ref: "#/components/schemas/" + g.component(t)}
+	}
+	return &Schema{}
+}
+
+// component 生成结构体的组件定义，名称默认为类型名，与已有组件同名时使用完整包路径区分，
+// 不同的匿名结构体依次命名为 Anonymous、Anonymous2 ...
+func (g *schemaGenerator) component(t reflect.Type) string {
+	if name, ok := g.names[t]; ok {
+		return name
+	}
+
+	name := g.componentName(t)
+	g.names[t] = name
+
+	s := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	g.schemas[name] = s
+	g.walkFields(t, func(f reflect.StructField) {
+		fieldName, ok := tagName(f, "json")
+		if !ok {
+			return
+		}
+		s.Properties[fieldName] = g.schema(f.Type)
+		if isRequired(f) {
+			s.Required = append(s.Required, fieldName)
+		}
+	})
+	sort.Strings(s.Required)
+	return name
+}
+
+var invalidComponentChars = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+func (g *schemaGenerator) componentName(t reflect.Type) string {
+	if t.Name() == "" {
+		name := "Anonymous"
+		for i := 2; g.schemas[name] != nil; i++ {
+			name = "Anonymous" + strconv.Itoa(i)
+		}
+		return name
+	}
+
+	// 组件名只能包含字母、数字及 ._-，泛型类型名中的 [ ] / 等字符替换为 _
+	name := invalidComponentChars.ReplaceAllString(t.Name(), "_")
+	if g.schemas[name] == nil {
+		return name
+	}
+	qualified := t.PkgPath() + "." + t.Name()
+	name = invalidComponentChars.ReplaceAllString(qualified, "_")
+	if g.schemas[name] == nil {
+		return name
+	}
+	// 替换字符后仍然冲突时追加完整名称的哈希
+	sum := sha1.Sum([]byte(qualified))
+	return name + "_" + hex.EncodeToString(sum[:4])
+}
+
+// tagName 返回字段在 tag 中的名称，json 未声明时使用字段名
+func tagName(f reflect.StructField, tag string) (string, bool) {
+	value, ok := f.Tag.Lookup(tag)
+	if !ok {
+		if tag == "json" {
+			return f.Name, true
+		}
+		return "", false
+	}
+	name := strings.Split(value, ",")[0]
+	if name == "-" {
+		return "", false
+	}
+	if name == "" {
+		name = f.Name
+	}
+	return name, true
+}
+
+func isRequired(f reflect.StructField) bool {
+	for _, rule := range strings.Split(f.Tag.Get("binding"), ",") {
+		if rule == "required" {
+			return true
+		}
+	}
+	return false
+}
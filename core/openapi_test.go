@@ -0,0 +1,166 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	htmltemplate "html/template"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	texttemplate "text/template"
+	"time"
+)
+
+type apiAddress struct {
+	City string `json:"city" binding:"required"`
+}
+
+type apiUser struct {
+	ID        int64             `json:"id"`
+	Name      string            `json:"name" binding:"required"`
+	Avatar    []byte            `json:"avatar"`
+	Tags      []string          `json:"tags"`
+	Labels    map[string]int    `json:"labels"`
+	Address   *apiAddress       `json:"address"`
+	Friends   []*apiUser        `json:"friends"`
+	CreatedAt time.Time         `json:"createdAt"`
+	Extra     struct{ V bool }  `json:"extra"`
+	Ignored   string            `json:"-"`
+	Meta      map[string]string `json:"meta,omitempty"`
+}
+
+type apiUpdateUser struct {
+	ID      int64  `uri:"id"`
+	Verbose bool   `form:"verbose"`
+	Token   string `header:"X-Token" binding:"required"`
+	Name    string `json:"name" binding:"required"`
+	Age     int    `json:"age"`
+}
+
+func TestOpenAPI(t *testing.T) {
+	m := newTestMux(t, WithName("users"), WithVersion("1.0.0"))
+	m2, _ := New(WithDisablePrometheus())
+	r := m.Router("/api/v1")
+	PUT(r, "/users/:id", func(ctx context.Context, req *apiUpdateUser) (*apiUser, error) {
+		return nil, nil
+	}, Summary("update user"), Tags("user"))
+	GET(r.Group("/users"), "/:id/*file", func(ctx context.Context, req *apiUpdateUser) (*[]apiUser, error) {
+		return nil, nil
+	})
+
+	doc := m.OpenAPI()
+	if doc.Info.Title != "users" || doc.Info.Version != "1.0.0" {
+		t.Fatalf("unexpected info %+v", doc.Info)
+	}
+	if m2.OpenAPI().Info.Title != "API" {
+		t.Fatal("expected default title")
+	}
+
+	put := doc.Paths["/api/v1/users/{id}"]["put"]
+	if put == nil || put.Summary != "update user" || !reflect.DeepEqual(put.Tags, []string{"user"}) {
+		t.Fatalf("unexpected put operation %+v", put)
+	}
+	params := map[string]*Parameter{}
+	for _, p := range put.Parameters {
+		params[p.In+":"+p.Name] = p
+	}
+	if p := params["path:id"]; p == nil || !p.Required || p.Schema.Type != "integer" || p.Schema.Format != "int64" {
+		t.Errorf("unexpected path param %+v", p)
+	}
+	if p := params["query:verbose"]; p == nil || p.Required || p.Schema.Type != "boolean" {
+		t.Errorf("unexpected query param %+v", p)
+	}
+	if p := params["header:X-Token"]; p == nil || !p.Required {
+		t.Errorf("unexpected header param %+v", p)
+	}
+	if len(put.Parameters) != 3 {
+		t.Errorf("expected 3 parameters, got %d", len(put.Parameters))
+	}
+	body := put.RequestBody.Content["application/json"].Schema
+	if len(body.Properties) != 2 || !reflect.DeepEqual(body.Required, []string{"name"}) {
+		t.Errorf("unexpected request body %+v", body)
+	}
+	data := put.Responses["200"].Content["application/json"].Schema.Properties["data"]
+	if data.Ref != "#/components/schemas/apiUser" {
+		t.Errorf("unexpected response data %+v", data)
+	}
+
+	get := doc.Paths["/api/v1/users/{id}/{file}"]["get"]
+	if get == nil || get.RequestBody != nil {
+		t.Fatalf("expected GET without request body, got %+v", get)
+	}
+	if data := get.Responses["200"].Content["application/json"].Schema.Properties["data"]; data.Type != "array" || data.Items.Ref != "#/components/schemas/apiUser" {
+		t.Errorf("unexpected list response %+v", data)
+	}
+
+	user := doc.Components.Schemas["apiUser"]
+	want := map[string]Schema{
+		"id":        {Type: "integer", Format: "int64"},
+		"avatar":    {Type: "string", Format: "byte"},
+		"createdAt": {Type: "string", Format: "date-time"},
+		"address":   {Ref: "#/components/schemas/apiAddress"},
+		"extra":     {Ref: "#/components/schemas/Anonymous"},
+	}
+	for name, s := range want {
+		if got := user.Properties[name]; got == nil || !reflect.DeepEqual(*got, s) {
+			t.Errorf("property %s: expected %+v, got %+v", name, s, got)
+		}
+	}
+	if user.Properties["friends"].Items.Ref != "#/components/schemas/apiUser" {
+		t.Errorf("expected recursive reference, got %+v", user.Properties["friends"])
+	}
+	if user.Properties["labels"].AdditionalProperties.Type != "integer" {
+		t.Errorf("unexpected map schema %+v", user.Properties["labels"])
+	}
+	if _, ok := user.Properties["Ignored"]; ok {
+		t.Error("unexpected property Ignored")
+	}
+	if len(user.Properties) != 10 || !reflect.DeepEqual(user.Required, []string{"name"}) {
+		t.Errorf("unexpected user schema %+v", user)
+	}
+	if doc.Components.Schemas["apiAddress"] == nil || doc.Components.Schemas["Anonymous"] == nil {
+		t.Errorf("missing components: %v", doc.Components.Schemas)
+	}
+
+	w := httptest.NewRecorder()
+	m2.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/docs/openapi.json", nil))
+	served := &OpenAPI{}
+	if err := json.Unmarshal(w.Body.Bytes(), served); err != nil || w.Code != http.StatusOK || served.OpenAPI != "3.0.3" {
+		t.Fatalf("unexpected /docs/openapi.json: %d %s", w.Code, w.Body.String())
+	}
+}
+
+type apiPage[T any] struct {
+	List []T `json:"list"`
+}
+
+func TestOpenAPIComponentNames(t *testing.T) {
+	g := &schemaGenerator{schemas: make(map[string]*Schema), names: make(map[reflect.Type]string)}
+	tests := []struct {
+		typ  reflect.Type
+		want string
+	}{
+		{reflect.TypeOf(texttemplate.Template{}), "Template"},
+		// 包名相同的同名类型使用完整包路径区分
+		{reflect.TypeOf(htmltemplate.Template{}), "html_template.Template"},
+		{reflect.TypeOf(texttemplate.Template{}), "Template"},
+		{reflect.TypeOf(struct{ A int }{}), "Anonymous"},
+		{reflect.TypeOf(struct{ B int }{}), "Anonymous2"},
+		{reflect.TypeOf(struct{ A int }{}), "Anonymous"},
+		{reflect.TypeOf(apiPage[apiUser]{}), "apiPage_github.com_ffhuo_go-kits_core.apiUser_"},
+	}
+	for _, tt := range tests {
+		if got := g.component(tt.typ); got != tt.want {
+			t.Errorf("component(%s) = %q, want %q", tt.typ, got, tt.want)
+		}
+	}
+	if g.schemas["Anonymous"].Properties["A"] == nil || g.schemas["Anonymous2"].Properties["B"] == nil {
+		t.Errorf("anonymous structs share a component: %+v %+v", g.schemas["Anonymous"], g.schemas["Anonymous2"])
+	}
+
+	// 替换字符后仍冲突时追加哈希
+	if got := g.componentName(reflect.TypeOf(htmltemplate.Template{})); len(got) != len("html_template.Template_")+8 {
+		t.Errorf("expected hashed name, got %q", got)
+	}
+}
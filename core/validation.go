@@ -0,0 +1,30 @@
+package core
+
+import (
+	"sync"
+
+	"github.com/ffhuo/go-kits/common/validation"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+var validationOnce sync.Once
+
+// registerValidations 向 gin 的校验器注册 common/validation 中的规则：
+//
+//	mobile       手机号
+//	email_strict 邮箱
+func registerValidations() {
+	validationOnce.Do(func() {
+		v, ok := binding.Validator.Engine().(*validator.Validate)
+		if !ok {
+			return
+		}
+		v.RegisterValidation("mobile", func(fl validator.FieldLevel) bool {
+			return validation.ValidMobile(fl.Field().String())
+		})
+		v.RegisterValidation("email_strict", func(fl validator.FieldLevel) bool {
+			return validation.ValidEamil(fl.Field().String())
+		})
+	})
+}
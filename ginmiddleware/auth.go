@@ -0,0 +1,496 @@
+package ginmiddleware
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ffhuo/go-kits/common/errno"
+	"github.com/ffhuo/go-kits/common/field"
+	"github.com/ffhuo/go-kits/ginmiddleware/response"
+	"github.com/ffhuo/go-kits/gout"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	// ClaimsKey gin context 中 JWT claims 的键
+	ClaimsKey = "jwt_claims"
+	// APIKeyInfoKey gin context 中 API Key 信息的键
+	APIKeyInfoKey = "api_key_info"
+	// SubjectKey 日志字段中认证主体的键
+	SubjectKey = "subject"
+)
+
+var defaultJWTAlgorithms = []string{"HS256", "RS256", "ES256"}
+
+// KeySource JWT 验签密钥来源
+type KeySource interface {
+	// Key 返回验签密钥：HS256 为 []byte，RS256 为 *rsa.PublicKey，ES256 为 *ecdsa.PublicKey
+	Key(ctx context.Context, token *jwt.Token) (interface{}, error)
+}
+
+// KeySourceFunc 函数形式的 KeySource
+type KeySourceFunc func(ctx context.Context, token *jwt.Token) (interface{}, error)
+
+func (f KeySourceFunc) Key(ctx context.Context, token *jwt.Token) (interface{}, error) {
+	return f(ctx, token)
+}
+
+// StaticKey 使用固定密钥验签
+func StaticKey(key interface{}) KeySource {
+	return KeySourceFunc(func(context.Context, *jwt.Token) (interface{}, error) {
+		return key, nil
+	})
+}
+
+// PEMFileKey 从 PEM 文件加载验签公钥，支持 common/x509cert.Generate 生成的证书和私钥
+func PEMFileKey(path string) (KeySource, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := ParsePublicKeyPEM(data)
+	if err != nil {
+		return nil, err
+	}
+	return StaticKey(key), nil
+}
+
+// ParsePublicKeyPEM 从 CERTIFICATE、PUBLIC KEY 或私钥 PEM 中解析出公钥
+func ParsePublicKeyPEM(data []byte) (crypto.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("invalid pem data")
+	}
+
+	switch block.Type {
+	case "CERTIFICATE":
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		return cert.PublicKey, nil
+	case "PUBLIC KEY":
+		return x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		return &key.PublicKey, nil
+	case "EC PRIVATE KEY":
+		key, err := x509.ParseECPrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		return &key.PublicKey, nil
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		if signer, ok := key.(crypto.Signer); ok {
+			return signer.Public(), nil
+		}
+		return nil, fmt.Errorf("unsupported private key type %T", key)
+	default:
+		return nil, fmt.Errorf("unsupported pem block type %q", block.Type)
+	}
+}
+
+// jwksKeySource 从 JWKS 地址获取密钥，按 kid 缓存
+type jwksKeySource struct {
+	url     string
+	refresh time.Duration
+	client  *gout.Client
+
+	mu        sync.Mutex
+	keys      map[string]interface{}
+	fetchedAt time.Time
+}
+
+// jwksMinInterval 遇到未知 kid 时两次拉取的最小间隔，避免伪造 kid 打满 JWKS 服务
+const jwksMinInterval = time.Minute
+
+// jwksTimeout 未指定 Client 时拉取 JWKS 的超时时间，拉取期间其它请求的验签会等待
+const jwksTimeout = 5 * time.Second
+
+// JWKSConfig JWKS 密钥来源配置
+type JWKSConfig struct {
+	URL     string        // JWKS 地址，必填
+	Refresh time.Duration // 缓存刷新周期，默认 1 小时，遇到未知的 kid 时会提前刷新
+	Client  *gout.Client  // 拉取 JWKS 使用的客户端，默认使用超时 5s 的独立客户端
+}
+
+// JWKSKey 从 JWKS 地址获取验签公钥，refresh 为缓存刷新周期，默认 1 小时，
+// 遇到未知的 kid 时会提前刷新
+func JWKSKey(url string, refresh time.Duration) KeySource {
+	return JWKSKeyWithConfig(&JWKSConfig{URL: url, Refresh: refresh})
+}
+
+// JWKSKeyWithConfig 同 JWKSKey，可指定拉取 JWKS 使用的客户端。只使用 RSA 及 P-256/P-384/P-521 EC 公钥，
+// 其它类型（包括对称密钥 oct）会被忽略
+func JWKSKeyWithConfig(config *JWKSConfig) KeySource {
+	s := &jwksKeySource{url: config.URL, refresh: config.Refresh, client: config.Client}
+	if s.refresh <= 0 {
+		s.refresh = time.Hour
+	}
+	if s.client == nil {
+		s.client = gout.NewClient(gout.WithTimeout(jwksTimeout))
+	}
+	return s
+}
+
+func (s *jwksKeySource) Key(ctx context.Context, token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, ok := s.lookup(kid)
+	since := time.Since(s.fetchedAt)
+	if ok && since < s.refresh {
+		return key, nil
+	}
+	if s.keys == nil || since >= s.refresh || since >= jwksMinInterval {
+		if err := s.fetch(ctx); err != nil {
+			// 拉取失败时继续使用旧密钥
+			if ok {
+				return key, nil
+			}
+			return nil, err
+		}
+		key, ok = s.lookup(kid)
+	}
+	if !ok {
+		return nil, fmt.Errorf("jwks: key %q not found", kid)
+	}
+	return key, nil
+}
+
+func (s *jwksKeySource) lookup(kid string) (interface{}, bool) {
+	if kid == "" && len(s.keys) == 1 {
+		for _, key := range s.keys {
+			return key, true
+		}
+	}
+	key, ok := s.keys[kid]
+	return key, ok
+}
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (s *jwksKeySource) fetch(ctx context.Context) error {
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	status, err := s.client.GET(s.url).WithContext(ctx).BindJSON(&set).Do()
+	if err != nil {
+		return fmt.Errorf("jwks: fetch %s: %v", s.url, err)
+	}
+	if status != http.StatusOK {
+		return fmt.Errorf("jwks: fetch %s: unexpected status %d", s.url, status)
+	}
+
+	// 跳过不支持或无法解析的密钥，IdP 新增其它类型的密钥时不影响已有密钥
+	keys := make(map[string]interface{}, len(set.Keys))
+	var skipped error
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.publicKey()
+		if err != nil {
+			if skipped == nil {
+				skipped = fmt.Errorf("jwks: key %q: %v", jwk.Kid, err)
+			}
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+	if len(keys) == 0 {
+		if skipped != nil {
+			return fmt.Errorf("jwks: fetch %s: no usable key, %v", s.url, skipped)
+		}
+		return fmt.Errorf("jwks: fetch %s: no usable key", s.url)
+	}
+	s.keys = keys
+	s.fetchedAt = time.Now()
+	return nil
+}
+
+func (k *jsonWebKey) publicKey() (interface{}, error) {
+	decode := base64.RawURLEncoding.DecodeString
+
+	switch k.Kty {
+	case "RSA":
+		n, err := decode(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decode(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decode(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decode(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+// JWTConfig JWT 认证中间件配置
+type JWTConfig struct {
+	KeySource  KeySource     // 验签密钥来源，必填
+	Algorithms []string      // 允许的签名算法，默认 HS256、RS256、ES256
+	Header     string        // 读取 token 的请求头，默认 Authorization，值为 Bearer <token>
+	QueryParam string        // 请求头中没有 token 时从该查询参数读取，为空则不读取
+	Issuer     string        // 校验 iss，为空则不校验
+	Audience   string        // 校验 aud，为空则不校验
+	Leeway     time.Duration // 校验 exp、nbf 时允许的时钟偏差
+	SkipPaths  []string      // 跳过认证的路径
+}
+
+// JWTAuth JWT 认证中间件，校验通过后 claims 写入 gin context（GetClaims 获取），
+// sub 写入日志字段
+func JWTAuth(config *JWTConfig) gin.HandlerFunc {
+	if config == nil || config.KeySource == nil {
+		panic("KeySource is required for JWTAuth middleware")
+	}
+	header := config.Header
+	if header == "" {
+		header = "Authorization"
+	}
+	algorithms := config.Algorithms
+	if len(algorithms) == 0 {
+		algorithms = defaultJWTAlgorithms
+	}
+
+	opts := []jwt.ParserOption{jwt.WithValidMethods(algorithms), jwt.WithLeeway(config.Leeway)}
+	if config.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(config.Issuer))
+	}
+	if config.Audience != "" {
+		opts = append(opts, jwt.WithAudience(config.Audience))
+	}
+	parser := jwt.NewParser(opts...)
+
+	return func(c *gin.Context) {
+		if contains(config.SkipPaths, c.Request.URL.Path) {
+			c.Next()
+			return
+		}
+
+		tokenString := bearerToken(c.GetHeader(header))
+		if tokenString == "" && config.QueryParam != "" {
+			tokenString = c.Query(config.QueryParam)
+		}
+		if tokenString == "" {
+			unauthorized(c, "missing token")
+			return
+		}
+
+		claims := jwt.MapClaims{}
+		_, err := parser.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+			return config.KeySource.Key(c.Request.Context(), token)
+		})
+		if err != nil {
+			unauthorized(c, err.Error())
+			return
+		}
+
+		c.Set(ClaimsKey, claims)
+		if sub, err := claims.GetSubject(); err == nil && sub != "" {
+			field.With(c, field.F(SubjectKey, sub))
+		}
+
+		c.Next()
+	}
+}
+
+// GetClaims 获取 JWTAuth 写入的 claims，支持 gin context 及 core 类型化 handler 的 ctx
+func GetClaims(ctx context.Context) jwt.MapClaims {
+	if claims, ok := ctx.Value(ClaimsKey).(jwt.MapClaims); ok {
+		return claims
+	}
+	return nil
+}
+
+func bearerToken(value string) string {
+	const prefix = "Bearer "
+	if len(value) > len(prefix) && strings.EqualFold(value[:len(prefix)], prefix) {
+		return strings.TrimSpace(value[len(prefix):])
+	}
+	return ""
+}
+
+func unauthorized(c *gin.Context, reason string) {
+	c.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+	response.Fail(c, errno.New(http.StatusUnauthorized).SetErrMsg("unauthorized: %s", reason))
+}
+
+// APIKeyInfo API Key 对应的调用方信息
+type APIKeyInfo struct {
+	ID         string            // 调用方标识，写入日志字段
+	Name       string            // 调用方名称
+	Attributes map[string]string // 其它属性
+}
+
+// APIKeyStore API Key 存储，key 不存在时返回 nil, nil
+type APIKeyStore interface {
+	Lookup(ctx context.Context, key string) (*APIKeyInfo, error)
+}
+
+type memoryAPIKeyStore struct {
+	keys map[string]*APIKeyInfo
+}
+
+// NewMemoryAPIKeyStore 使用内存中的 key 列表
+func NewMemoryAPIKeyStore(keys map[string]*APIKeyInfo) APIKeyStore {
+	return &memoryAPIKeyStore{keys: keys}
+}
+
+func (s *memoryAPIKeyStore) Lookup(_ context.Context, key string) (*APIKeyInfo, error) {
+	return s.keys[key], nil
+}
+
+// HashGetter 读取 Redis hash 的客户端，redis.RedisCli 满足该接口
+type HashGetter interface {
+	HgetAll(key string) (map[string]string, error)
+}
+
+type redisAPIKeyStore struct {
+	client HashGetter
+	prefix string
+}
+
+// NewRedisAPIKeyStore 使用 Redis hash 存储 API Key，键为 prefix+apiKey，
+// 字段 id、name 对应 APIKeyInfo.ID、Name，全部字段保存在 Attributes 中
+func NewRedisAPIKeyStore(client HashGetter, prefix string) APIKeyStore {
+	return &redisAPIKeyStore{client: client, prefix: prefix}
+}
+
+func (s *redisAPIKeyStore) Lookup(_ context.Context, key string) (*APIKeyInfo, error) {
+	values, err := s.client.HgetAll(s.prefix + key)
+	if err != nil {
+		return nil, err
+	}
+	if len(values) == 0 {
+		return nil, nil
+	}
+	return &APIKeyInfo{
+		ID:         values["id"],
+		Name:       values["name"],
+		Attributes: values,
+	}, nil
+}
+
+// APIKeyConfig API Key 认证中间件配置
+type APIKeyConfig struct {
+	Store      APIKeyStore // API Key 存储，必填
+	Header     string      // 读取 key 的请求头，默认 X-API-Key
+	QueryParam string      // 请求头中没有 key 时从该查询参数读取，为空则不读取
+	SkipPaths  []string    // 跳过认证的路径
+	Logger     Logger      // 存储出错时记录日志
+}
+
+// APIKeyAuth API Key 认证中间件，校验通过后 APIKeyInfo 写入 gin context（GetAPIKeyInfo 获取），
+// ID 写入日志字段
+func APIKeyAuth(config *APIKeyConfig) gin.HandlerFunc {
+	if config == nil || config.Store == nil {
+		panic("Store is required for APIKeyAuth middleware")
+	}
+	header := config.Header
+	if header == "" {
+		header = "X-API-Key"
+	}
+
+	return func(c *gin.Context) {
+		if contains(config.SkipPaths, c.Request.URL.Path) {
+			c.Next()
+			return
+		}
+
+		key := c.GetHeader(header)
+		if key == "" && config.QueryParam != "" {
+			key = c.Query(config.QueryParam)
+		}
+		if key == "" {
+			response.Fail(c, errno.New(http.StatusUnauthorized).SetErrMsg("unauthorized: missing api key"))
+			return
+		}
+
+		info, err := config.Store.Lookup(c.Request.Context(), key)
+		if err != nil {
+			if config.Logger != nil {
+				config.Logger.Error(c, "api key lookup error: %v", err)
+			}
+			response.Fail(c, errno.New(http.StatusServiceUnavailable).SetErrMsg("api key store unavailable"))
+			return
+		}
+		if info == nil {
+			response.Fail(c, errno.New(http.StatusUnauthorized).SetErrMsg("unauthorized: invalid api key"))
+			return
+		}
+
+		c.Set(APIKeyInfoKey, info)
+		if info.ID != "" {
+			field.With(c, field.F(SubjectKey, info.ID))
+		}
+
+		c.Next()
+	}
+}
+
+// GetAPIKeyInfo 获取 APIKeyAuth 写入的调用方信息
+func GetAPIKeyInfo(ctx context.Context) *APIKeyInfo {
+	if info, ok := ctx.Value(APIKeyInfoKey).(*APIKeyInfo); ok {
+		return info
+	}
+	return nil
+}
@@ -0,0 +1,360 @@
+package ginmiddleware
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ffhuo/go-kits/common/errno"
+	"github.com/ffhuo/go-kits/common/metrics"
+	"github.com/ffhuo/go-kits/ginmiddleware/response"
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// BreakerState 熔断器状态，数值即导出的 gauge 值
+type BreakerState int
+
+const (
+	StateClosed BreakerState = iota
+	StateHalfOpen
+	StateOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateHalfOpen:
+		return "half-open"
+	case StateOpen:
+		return "open"
+	default:
+		return "unknown"
+	}
+}
+
+var (
+	// ErrBreakerOpen 熔断器打开或半开状态探测请求已满
+	ErrBreakerOpen = errors.New("circuit breaker is open")
+	// ErrBulkheadFull 并发数已达上限
+	ErrBulkheadFull = errors.New("too many concurrent requests")
+)
+
+// BreakerConfig 熔断器配置
+type BreakerConfig struct {
+	FailureRatio     float64       // 窗口内失败比例达到该值时打开，默认 0.5
+	MinRequests      int           // 窗口内请求数达到该值才计算失败比例，默认 20
+	Window           time.Duration // 关闭状态下的统计窗口，默认 10s
+	CoolDown         time.Duration // 打开状态持续时间，之后进入半开状态，默认 30s
+	HalfOpenRequests int           // 半开状态允许的探测请求数，全部成功后关闭，默认 1
+
+	// OnStateChange 状态变化回调
+	OnStateChange func(name string, from, to BreakerState)
+}
+
+// DefaultBreakerConfig 默认配置
+func DefaultBreakerConfig() *BreakerConfig {
+	return &BreakerConfig{
+		FailureRatio:     0.5,
+		MinRequests:      20,
+		Window:           10 * time.Second,
+		CoolDown:         30 * time.Second,
+		HalfOpenRequests: 1,
+	}
+}
+
+func (cfg *BreakerConfig) withDefaults() *BreakerConfig {
+	def := DefaultBreakerConfig()
+	if cfg == nil {
+		return def
+	}
+	c := *cfg
+	if c.FailureRatio <= 0 {
+		c.FailureRatio = def.FailureRatio
+	}
+	if c.MinRequests <= 0 {
+		c.MinRequests = def.MinRequests
+	}
+	if c.Window <= 0 {
+		c.Window = def.Window
+	}
+	if c.CoolDown <= 0 {
+		c.CoolDown = def.CoolDown
+	}
+	if c.HalfOpenRequests <= 0 {
+		c.HalfOpenRequests = def.HalfOpenRequests
+	}
+	return &c
+}
+
+// Breaker 熔断器，可独立用于保护任意下游调用
+type Breaker struct {
+	name string
+	cfg  *BreakerConfig
+
+	mu          sync.Mutex
+	state       BreakerState
+	generation  uint64 // 每次状态变化加一，忽略上一状态期间发起的请求结果
+	requests    int
+	failures    int
+	windowStart time.Time
+	openedAt    time.Time
+	probes      int // 半开状态已放行的探测请求数
+	successes   int // 半开状态成功的探测请求数
+}
+
+// NewBreaker 创建熔断器
+func NewBreaker(name string, config *BreakerConfig) *Breaker {
+	return &Breaker{
+		name:        name,
+		cfg:         config.withDefaults(),
+		windowStart: time.Now(),
+	}
+}
+
+// Name 熔断器名称
+func (b *Breaker) Name() string {
+	return b.name
+}
+
+// State 当前状态
+func (b *Breaker) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refresh(time.Now())
+	return b.state
+}
+
+// RetryAfter 打开状态下距离进入半开状态的时间
+func (b *Breaker) RetryAfter() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state != StateOpen {
+		return 0
+	}
+	return b.cfg.CoolDown - time.Since(b.openedAt)
+}
+
+// Allow 判断请求是否可以放行，放行时需在请求结束后调用 done 上报结果
+func (b *Breaker) Allow() (done func(success bool), err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	b.refresh(now)
+
+	switch b.state {
+	case StateOpen:
+		return nil, ErrBreakerOpen
+	case StateHalfOpen:
+		if b.probes >= b.cfg.HalfOpenRequests {
+			return nil, ErrBreakerOpen
+		}
+		b.probes++
+	default:
+		b.requests++
+	}
+
+	generation := b.generation
+	var once sync.Once
+	return func(success bool) {
+		once.Do(func() { b.done(generation, success) })
+	}, nil
+}
+
+// Execute 在熔断器保护下执行 fn，fn 返回错误视为失败
+func (b *Breaker) Execute(fn func() error) error {
+	done, err := b.Allow()
+	if err != nil {
+		return err
+	}
+	err = fn()
+	done(err == nil)
+	return err
+}
+
+func (b *Breaker) done(generation uint64, success bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	b.refresh(now)
+	if generation != b.generation {
+		return
+	}
+
+	switch b.state {
+	case StateHalfOpen:
+		if !success {
+			b.setState(StateOpen, now)
+			return
+		}
+		b.successes++
+		if b.successes >= b.cfg.HalfOpenRequests {
+			b.setState(StateClosed, now)
+		}
+	case StateClosed:
+		if !success {
+			b.failures++
+		}
+		if b.requests >= b.cfg.MinRequests &&
+			float64(b.failures)/float64(b.requests) >= b.cfg.FailureRatio {
+			b.setState(StateOpen, now)
+		}
+	}
+}
+
+// refresh 处理基于时间的状态变化：冷却结束进入半开，统计窗口到期清零
+func (b *Breaker) refresh(now time.Time) {
+	switch b.state {
+	case StateOpen:
+		if now.Sub(b.openedAt) >= b.cfg.CoolDown {
+			b.setState(StateHalfOpen, now)
+		}
+	case StateClosed:
+		if now.Sub(b.windowStart) >= b.cfg.Window {
+			b.requests, b.failures = 0, 0
+			b.windowStart = now
+			b.generation++
+		}
+	}
+}
+
+func (b *Breaker) setState(state BreakerState, now time.Time) {
+	from := b.state
+	b.state = state
+	b.generation++
+	b.requests, b.failures = 0, 0
+	b.probes, b.successes = 0, 0
+	b.windowStart = now
+	if state == StateOpen {
+		b.openedAt = now
+	}
+	if b.cfg.OnStateChange != nil && from != state {
+		b.cfg.OnStateChange(b.name, from, state)
+	}
+}
+
+// CircuitBreakerConfig 熔断及舱壁中间件配置
+type CircuitBreakerConfig struct {
+	Breaker       *BreakerConfig            // 熔断器配置，为空使用 DefaultBreakerConfig
+	KeyFunc       KeyFunc                   // 熔断的粒度，默认按路由，取值必须是有限集合，见 CircuitBreaker
+	IsFailure     func(c *gin.Context) bool // 判断请求是否失败，默认状态码 >= 500
+	MaxConcurrent int                       // 每个键的最大并发数，<=0 表示不限制
+	SkipPaths     []string                  // 跳过的路径
+	Registerer    prometheus.Registerer     // 熔断器状态及并发数指标的注册器，默认 prometheus.DefaultRegisterer
+}
+
+const (
+	breakerStateGauge     = "circuit_breaker_state"
+	bulkheadInFlightGauge = "bulkhead_in_flight"
+)
+
+// CircuitBreaker 熔断及舱壁中间件，熔断器打开或并发数超限时返回 503。
+// 每个键的熔断器、并发限制及指标 label 创建后不会回收，KeyFunc 应返回路由、下游服务等低基数的键，
+// 不能按客户端 IP、用户或租户划分，否则内存及 Prometheus 序列数会无限增长
+func CircuitBreaker(config ...*CircuitBreakerConfig) gin.HandlerFunc {
+	cfg := &CircuitBreakerConfig{}
+	if len(config) > 0 && config[0] != nil {
+		cfg = config[0]
+	}
+	keyFunc := cfg.KeyFunc
+	if keyFunc == nil {
+		keyFunc = KeyByRoute()
+	}
+	isFailure := cfg.IsFailure
+	if isFailure == nil {
+		isFailure = func(c *gin.Context) bool {
+			return c.Writer.Status() >= http.StatusInternalServerError
+		}
+	}
+
+	stateGauge := metrics.MustRegister(cfg.Registerer, prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: breakerStateGauge,
+		Help: "The state of circuit breaker, 0: closed, 1: half-open, 2: open.",
+	}, []string{"key"}))
+	inFlightGauge := metrics.MustRegister(cfg.Registerer, prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: bulkheadInFlightGauge,
+		Help: "The number of in-flight requests guarded by bulkhead.",
+	}, []string{"key"}))
+
+	breakerConfig := cfg.Breaker.withDefaults()
+	onStateChange := breakerConfig.OnStateChange
+	breakerConfig.OnStateChange = func(name string, from, to BreakerState) {
+		stateGauge.WithLabelValues(name).Set(float64(to))
+		if onStateChange != nil {
+			onStateChange(name, from, to)
+		}
+	}
+
+	var (
+		mu        sync.Mutex
+		breakers  = make(map[string]*Breaker)
+		bulkheads = make(map[string]chan struct{})
+	)
+	get := func(key string) (*Breaker, chan struct{}) {
+		mu.Lock()
+		defer mu.Unlock()
+		b, ok := breakers[key]
+		if !ok {
+			b = NewBreaker(key, breakerConfig)
+			breakers[key] = b
+			stateGauge.WithLabelValues(key).Set(float64(StateClosed))
+			if cfg.MaxConcurrent > 0 {
+				bulkheads[key] = make(chan struct{}, cfg.MaxConcurrent)
+			}
+		}
+		return b, bulkheads[key]
+	}
+
+	return func(c *gin.Context) {
+		if contains(cfg.SkipPaths, c.Request.URL.Path) {
+			c.Next()
+			return
+		}
+
+		key := keyFunc(c)
+		breaker, bulkhead := get(key)
+
+		if bulkhead != nil {
+			select {
+			case bulkhead <- struct{}{}:
+				inFlight := inFlightGauge.WithLabelValues(key)
+				inFlight.Inc()
+				defer func() {
+					<-bulkhead
+					inFlight.Dec()
+				}()
+			default:
+				serviceUnavailable(c, ErrBulkheadFull, 0)
+				return
+			}
+		}
+
+		done, err := breaker.Allow()
+		if err != nil {
+			serviceUnavailable(c, err, breaker.RetryAfter())
+			return
+		}
+		defer func() {
+			if r := recover(); r != nil {
+				done(false)
+				panic(r)
+			}
+		}()
+
+		c.Next()
+
+		done(!isFailure(c))
+	}
+}
+
+func serviceUnavailable(c *gin.Context, err error, retryAfter time.Duration) {
+	if retryAfter > 0 {
+		c.Header("Retry-After", strconv.Itoa(ceilSeconds(retryAfter)))
+	}
+	response.Fail(c, errno.New(http.StatusServiceUnavailable).SetErrMsg("service unavailable: %v", err))
+}
@@ -0,0 +1,483 @@
+package ginmiddleware
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	EncodingBrotli  = "br"
+	EncodingGzip    = "gzip"
+	EncodingDeflate = "deflate"
+)
+
+// CompressConfig 压缩及 ETag 中间件配置
+type CompressConfig struct {
+	MinSize      int      // 小于该大小的响应不压缩
+	ContentTypes []string // 允许压缩的 Content-Type，以 / 结尾表示前缀匹配，例如 text/
+	Encodings    []string // 支持的编码，按优先级排列
+	SkipPaths    []string // 跳过压缩和 ETag 的路径
+	ETag         bool     // 是否为 GET/HEAD 的 200 响应生成弱 ETag 并处理 If-None-Match
+	ETagMaxSize  int      // 生成 ETag 时最多缓存的响应大小，超过后直接流式压缩且不生成 ETag，默认 1MB
+}
+
+// DefaultCompressConfig 默认配置
+func DefaultCompressConfig() *CompressConfig {
+	return &CompressConfig{
+		MinSize: 1024,
+		ContentTypes: []string{
+			"text/",
+			"application/json",
+			"application/javascript",
+			"application/xml",
+			"application/problem+json",
+			"image/svg+xml",
+		},
+		Encodings:   []string{EncodingBrotli, EncodingGzip, EncodingDeflate},
+		ETag:        true,
+		ETagMaxSize: 1 << 20,
+	}
+}
+
+// Compress 根据 Accept-Encoding 压缩响应，并为可压缩的响应生成弱 ETag。
+// 是否压缩在处理函数首次写入时按 Content-Type、Content-Length 决定：不可压缩的响应直接输出；
+// 可压缩的响应最多缓存 MinSize（需要生成 ETag 时为 ETagMaxSize）字节，超过后流式压缩输出。
+// 处理函数在压缩开始前调用 Flush 时（如 SSE）改为直接输出且不再压缩。
+// 需要在 Logger、审计等捕获响应内容的中间件之前注册，使它们拿到未压缩的内容。
+func Compress(config ...*CompressConfig) gin.HandlerFunc {
+	var cfg *CompressConfig
+	if len(config) > 0 && config[0] != nil {
+		cfg = config[0]
+	} else {
+		cfg = DefaultCompressConfig()
+	}
+	opts := compressOptions{
+		minSize:      cfg.MinSize,
+		contentTypes: cfg.ContentTypes,
+		encodings:    cfg.Encodings,
+		etag:         cfg.ETag,
+		etagMaxSize:  cfg.ETagMaxSize,
+	}
+	if opts.contentTypes == nil {
+		opts.contentTypes = DefaultCompressConfig().ContentTypes
+	}
+	if opts.encodings == nil {
+		opts.encodings = DefaultCompressConfig().Encodings
+	}
+	if opts.etagMaxSize <= 0 {
+		opts.etagMaxSize = DefaultCompressConfig().ETagMaxSize
+	}
+
+	return func(c *gin.Context) {
+		if contains(cfg.SkipPaths, c.Request.URL.Path) {
+			c.Next()
+			return
+		}
+
+		w := &compressWriter{ResponseWriter: c.Writer, opts: &opts, req: c.Request, status: http.StatusOK}
+		c.Writer = w
+		defer func() {
+			c.Writer = w.ResponseWriter
+		}()
+
+		c.Next()
+		w.finish()
+	}
+}
+
+type compressOptions struct {
+	minSize      int
+	contentTypes []string
+	encodings    []string
+	etag         bool
+	etagMaxSize  int
+}
+
+type compressMode int
+
+const (
+	modeUndecided   compressMode = iota
+	modeBuffer                   // 可压缩，缓存中，等待达到 MinSize 或计算 ETag
+	modeCompress                 // 流式压缩输出
+	modePassthrough              // 直接输出
+)
+
+// compressWriter 在首次写入时决定输出方式，只缓存有限大小的内容
+type compressWriter struct {
+	gin.ResponseWriter
+	opts   *compressOptions
+	req    *http.Request
+	status int
+
+	mode     compressMode
+	written  bool
+	size     int // 处理函数写入的未压缩字节数
+	buf      bytes.Buffer
+	limit    int  // modeBuffer 下最多缓存的字节数
+	large    bool // Content-Length 已知且不小于 MinSize
+	etag     bool // 响应结束前全部缓存时生成 ETag
+	encoding string
+	zw       resetWriteCloser
+}
+
+func (w *compressWriter) WriteHeader(code int) {
+	if w.mode == modePassthrough || w.mode == modeCompress {
+		return
+	}
+	if code > 0 {
+		w.status = code
+	}
+}
+
+func (w *compressWriter) WriteHeaderNow() {
+	w.written = true
+}
+
+func (w *compressWriter) Write(b []byte) (int, error) {
+	w.written = true
+	if w.mode == modeUndecided {
+		w.decide(b)
+	}
+	w.size += len(b)
+
+	switch w.mode {
+	case modeBuffer:
+		n, _ := w.buf.Write(b)
+		if w.buf.Len() > w.limit {
+			if err := w.startStream(); err != nil {
+				return 0, err
+			}
+		}
+		return n, nil
+	case modeCompress:
+		return w.zw.Write(b)
+	default:
+		return w.ResponseWriter.Write(b)
+	}
+}
+
+func (w *compressWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *compressWriter) Status() int {
+	return w.status
+}
+
+func (w *compressWriter) Size() int {
+	if !w.written {
+		return -1
+	}
+	return w.size
+}
+
+func (w *compressWriter) Written() bool {
+	return w.written
+}
+
+// decide 按状态码、响应头及首次写入的内容决定输出方式
+func (w *compressWriter) decide(first []byte) {
+	header := w.Header()
+	if header.Get("Content-Type") == "" {
+		header.Set("Content-Type", http.DetectContentType(first))
+	}
+
+	if !w.compressible() {
+		w.passthrough()
+		return
+	}
+	header.Add("Vary", "Accept-Encoding")
+	w.encoding = negotiateEncoding(w.req.Header.Get("Accept-Encoding"), w.opts.encodings)
+	w.etag = w.opts.etag && w.status == http.StatusOK &&
+		(w.req.Method == http.MethodGet || w.req.Method == http.MethodHead)
+
+	if w.encoding == "" && !w.etag {
+		w.passthrough()
+		return
+	}
+	length, err := strconv.Atoi(header.Get("Content-Length"))
+	if err == nil && length < w.opts.minSize && !w.etag {
+		w.passthrough()
+		return
+	}
+
+	w.mode = modeBuffer
+	w.large = err == nil && length >= w.opts.minSize
+	w.limit = w.opts.minSize
+	if w.etag && w.opts.etagMaxSize > w.limit {
+		w.limit = w.opts.etagMaxSize
+	}
+	if err == nil && length > w.limit {
+		w.limit = 0
+	}
+}
+
+func (w *compressWriter) compressible() bool {
+	if w.status < http.StatusOK || w.status == http.StatusNoContent || w.status == http.StatusNotModified {
+		return false
+	}
+	header := w.Header()
+	// 范围响应的 Content-Range 指向未压缩内容的偏移，压缩后会失效
+	if header.Get("Content-Encoding") != "" || w.status == http.StatusPartialContent || header.Get("Content-Range") != "" {
+		return false
+	}
+	mediaType, _, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		return false
+	}
+	for _, ct := range w.opts.contentTypes {
+		if mediaType == ct || (strings.HasSuffix(ct, "/") && strings.HasPrefix(mediaType, ct)) {
+			return true
+		}
+	}
+	return false
+}
+
+func (w *compressWriter) passthrough() {
+	w.mode = modePassthrough
+	w.ResponseWriter.WriteHeader(w.status)
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+// startStream 缓存超过上限后放弃 ETag，已缓存的内容达到 MinSize 时开始流式压缩，否则直接输出
+func (w *compressWriter) startStream() error {
+	buffered := w.buf.Bytes()
+	if w.encoding == "" || (w.buf.Len() < w.opts.minSize && !w.large) {
+		w.passthrough()
+	} else {
+		zw, err := newCompressWriter(w.encoding)
+		if err != nil {
+			w.passthrough()
+		} else {
+			header := w.Header()
+			header.Set("Content-Encoding", w.encoding)
+			header.Del("Content-Length")
+			w.ResponseWriter.WriteHeader(w.status)
+			w.ResponseWriter.WriteHeaderNow()
+			zw.Reset(w.ResponseWriter)
+			w.zw = zw
+			w.mode = modeCompress
+		}
+	}
+
+	var err error
+	if len(buffered) > 0 {
+		if w.mode == modeCompress {
+			_, err = w.zw.Write(buffered)
+		} else {
+			_, err = w.ResponseWriter.Write(buffered)
+		}
+	}
+	w.buf = bytes.Buffer{}
+	return err
+}
+
+// finish 处理函数返回后输出缓存的内容或结束压缩流
+func (w *compressWriter) finish() {
+	switch w.mode {
+	case modePassthrough:
+		return
+	case modeCompress:
+		_ = w.zw.Close()
+		releaseCompressWriter(w.encoding, w.zw)
+		w.zw = nil
+		return
+	case modeUndecided:
+		w.ResponseWriter.WriteHeader(w.status)
+		w.ResponseWriter.WriteHeaderNow()
+		return
+	}
+
+	body := w.buf.Bytes()
+	header := w.Header()
+	if w.etag && len(body) > 0 {
+		etag := header.Get("ETag")
+		if etag == "" {
+			etag = weakETag(body)
+			header.Set("ETag", etag)
+		}
+		if etagMatch(w.req.Header.Get("If-None-Match"), etag) {
+			header.Del("Content-Length")
+			header.Del("Content-Type")
+			w.ResponseWriter.WriteHeader(http.StatusNotModified)
+			w.ResponseWriter.WriteHeaderNow()
+			return
+		}
+	}
+
+	if w.encoding != "" && len(body) > 0 && len(body) >= w.opts.minSize {
+		if compressed, err := compressBody(w.encoding, body); err == nil {
+			header.Set("Content-Encoding", w.encoding)
+			body = compressed
+		}
+	}
+	if len(body) > 0 {
+		header.Set("Content-Length", strconv.Itoa(len(body)))
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+	w.ResponseWriter.WriteHeaderNow()
+	if len(body) > 0 {
+		_, _ = w.ResponseWriter.Write(body)
+	}
+}
+
+// Flush 缓存中的响应放弃压缩直接输出，已开始压缩的响应刷新压缩器后再刷新底层 writer
+func (w *compressWriter) Flush() {
+	switch w.mode {
+	case modeUndecided:
+		w.passthrough()
+	case modeBuffer:
+		w.encoding = ""
+		_ = w.startStream()
+	case modeCompress:
+		if f, ok := w.zw.(interface{ Flush() error }); ok {
+			_ = f.Flush()
+		}
+	}
+	w.ResponseWriter.Flush()
+}
+
+func (w *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	w.mode = modePassthrough
+	return w.ResponseWriter.Hijack()
+}
+
+// negotiateEncoding 按 Accept-Encoding 的 q 值选择编码，q 值相同时按 supported 的顺序
+func negotiateEncoding(accept string, supported []string) string {
+	if accept == "" {
+		return ""
+	}
+
+	qualities := make(map[string]float64)
+	for _, part := range strings.Split(accept, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				q = f
+			}
+		}
+		qualities[strings.ToLower(strings.TrimSpace(name))] = q
+	}
+
+	candidates := make([]string, 0, len(supported))
+	for _, enc := range supported {
+		q, ok := qualities[enc]
+		if !ok {
+			q, ok = qualities["*"]
+		}
+		if ok && q > 0 {
+			candidates = append(candidates, enc)
+		}
+	}
+	if len(candidates) == 0 {
+		return ""
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return quality(qualities, candidates[i]) > quality(qualities, candidates[j])
+	})
+	return candidates[0]
+}
+
+func quality(qualities map[string]float64, enc string) float64 {
+	if q, ok := qualities[enc]; ok {
+		return q
+	}
+	return qualities["*"]
+}
+
+var (
+	gzipWriterPool   = sync.Pool{New: func() interface{} { return gzip.NewWriter(io.Discard) }}
+	zlibWriterPool   = sync.Pool{New: func() interface{} { return zlib.NewWriter(io.Discard) }}
+	brotliWriterPool = sync.Pool{New: func() interface{} { return brotli.NewWriter(io.Discard) }}
+)
+
+type resetWriteCloser interface {
+	io.WriteCloser
+	Reset(w io.Writer)
+}
+
+func compressWriterPool(encoding string) (*sync.Pool, error) {
+	switch encoding {
+	case EncodingGzip:
+		return &gzipWriterPool, nil
+	case EncodingDeflate:
+		// HTTP 的 deflate 编码为 zlib 格式（RFC 9110）
+		return &zlibWriterPool, nil
+	case EncodingBrotli:
+		return &brotliWriterPool, nil
+	}
+	return nil, fmt.Errorf("unsupported encoding %s", encoding)
+}
+
+func newCompressWriter(encoding string) (resetWriteCloser, error) {
+	pool, err := compressWriterPool(encoding)
+	if err != nil {
+		return nil, err
+	}
+	return pool.Get().(resetWriteCloser), nil
+}
+
+func releaseCompressWriter(encoding string, zw resetWriteCloser) {
+	if pool, err := compressWriterPool(encoding); err == nil {
+		zw.Reset(io.Discard)
+		pool.Put(zw)
+	}
+}
+
+func compressBody(encoding string, body []byte) ([]byte, error) {
+	zw, err := newCompressWriter(encoding)
+	if err != nil {
+		return nil, err
+	}
+	defer releaseCompressWriter(encoding, zw)
+
+	var out bytes.Buffer
+	zw.Reset(&out)
+	if _, err := zw.Write(body); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// weakETag 根据未压缩的响应内容生成弱 ETag，不同编码的响应共用同一个 ETag
+func weakETag(body []byte) string {
+	h := fnv.New64a()
+	_, _ = h.Write(body)
+	return fmt.Sprintf(`W/"%x-%x"`, len(body), h.Sum64())
+}
+
+// etagMatch If-None-Match 使用弱比较
+func etagMatch(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	if strings.TrimSpace(ifNoneMatch) == "*" {
+		return true
+	}
+	target := strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(candidate), "W/") == target {
+			return true
+		}
+	}
+	return false
+}
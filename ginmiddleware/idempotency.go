@@ -0,0 +1,340 @@
+package ginmiddleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ffhuo/go-kits/common/errno"
+	"github.com/ffhuo/go-kits/ginmiddleware/response"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	// IdempotencyKeyHeader 幂等键请求头
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// IdempotentReplayedHeader 重放的响应会带上该响应头
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+)
+
+// IdempotencyRecord 保存的首次响应
+type IdempotencyRecord struct {
+	Status      int         `json:"status"`
+	Header      http.Header `json:"header"`
+	Body        []byte      `json:"body"`
+	Fingerprint string      `json:"fingerprint"` // 请求体摘要，同一个幂等键只能用于相同的请求
+}
+
+// IdempotencyStore 幂等记录存储
+type IdempotencyStore interface {
+	// Lock 加锁，已被其它请求持有时返回 false；token 标识本次持有者，解锁时传回
+	Lock(ctx context.Context, key string, ttl time.Duration) (token string, locked bool, err error)
+	// Unlock 仅当锁仍由 token 持有时释放，锁已超时并被其它请求获取时不做任何操作
+	Unlock(ctx context.Context, key, token string) error
+	// Get 获取记录，不存在时返回 nil, nil
+	Get(ctx context.Context, key string) (*IdempotencyRecord, error)
+	Save(ctx context.Context, key string, record *IdempotencyRecord, ttl time.Duration) error
+}
+
+// IdempotencyConfig 幂等中间件配置
+type IdempotencyConfig struct {
+	Store       IdempotencyStore // 幂等记录存储，必填
+	Methods     []string         // 需要处理的请求方法，默认 POST
+	Required    bool             // 缺少 Idempotency-Key 时是否返回 400，默认直接放行
+	KeyFunc     KeyFunc          // 幂等键的作用域，默认按路由，可按用户等维度隔离
+	TTL         time.Duration    // 响应保存时间，默认 24h
+	LockTimeout time.Duration    // 处理中加锁的最长时间，默认 30s
+	MaxBodySize int64            // 计算请求摘要时读取的最大请求体，超过时返回 413，默认 1MB
+	Logger      Logger           // 存储出错时记录日志
+}
+
+// Idempotency 幂等中间件：保存带 Idempotency-Key 请求的首次响应（状态码、响应头、响应体），
+// 重试时直接重放；首次请求处理中时重复请求返回 409，同一个键用于不同请求体时返回 422。
+// 5xx 响应不保存，客户端可以使用同一个键重试。
+func Idempotency(config *IdempotencyConfig) gin.HandlerFunc {
+	if config == nil || config.Store == nil {
+		panic("Store is required for Idempotency middleware")
+	}
+	methods := config.Methods
+	if len(methods) == 0 {
+		methods = []string{http.MethodPost}
+	}
+	keyFunc := config.KeyFunc
+	if keyFunc == nil {
+		keyFunc = KeyByRoute()
+	}
+	ttl := config.TTL
+	if ttl <= 0 {
+		ttl = 24 * time.Hour
+	}
+	lockTimeout := config.LockTimeout
+	if lockTimeout <= 0 {
+		lockTimeout = 30 * time.Second
+	}
+	maxBodySize := config.MaxBodySize
+	if maxBodySize <= 0 {
+		maxBodySize = 1 << 20
+	}
+
+	logError := func(c *gin.Context, msg string, err error) {
+		if config.Logger != nil {
+			config.Logger.Error(c, msg, err)
+		}
+	}
+
+	return func(c *gin.Context) {
+		if !contains(methods, c.Request.Method) {
+			c.Next()
+			return
+		}
+
+		idempotencyKey := c.GetHeader(IdempotencyKeyHeader)
+		if idempotencyKey == "" {
+			if config.Required {
+				response.Fail(c, errno.New(http.StatusBadRequest).SetErrMsg("missing %s header", IdempotencyKeyHeader))
+				return
+			}
+			c.Next()
+			return
+		}
+
+		ctx := c.Request.Context()
+		key := keyFunc(c) + ":" + idempotencyKey
+		fingerprint, err := requestFingerprint(c, maxBodySize)
+		if errors.Is(err, errBodyTooLarge) {
+			response.Fail(c, errno.New(http.StatusRequestEntityTooLarge).SetErrMsg("request body exceeds %d bytes", maxBodySize))
+			return
+		}
+		if err != nil {
+			response.Fail(c, errno.New(http.StatusBadRequest).SetErrMsg("read request body: %v", err))
+			return
+		}
+
+		if replayed := replayIdempotent(c, config.Store, key, fingerprint, logError); replayed {
+			return
+		}
+
+		token, locked, err := config.Store.Lock(ctx, key, lockTimeout)
+		if err != nil {
+			logError(c, "idempotency lock error: %v", err)
+			response.Fail(c, errno.New(http.StatusServiceUnavailable).SetErrMsg("idempotency store unavailable"))
+			return
+		}
+		if !locked {
+			response.Fail(c, errno.New(http.StatusConflict).SetErrMsg("request with the same %s is in progress", IdempotencyKeyHeader))
+			return
+		}
+		defer func() {
+			if err := config.Store.Unlock(context.WithoutCancel(ctx), key, token); err != nil {
+				logError(c, "idempotency unlock error: %v", err)
+			}
+		}()
+
+		// 加锁前首次请求可能刚好完成
+		if replayed := replayIdempotent(c, config.Store, key, fingerprint, logError); replayed {
+			return
+		}
+
+		body := &bytes.Buffer{}
+		c.Writer = bodyLogWriter{ResponseWriter: c.Writer, body: body}
+
+		c.Next()
+
+		status := c.Writer.Status()
+		if status >= http.StatusInternalServerError {
+			return
+		}
+
+		header := c.Writer.Header().Clone()
+		header.Del("Content-Length")
+		header.Del("Date")
+		header.Del("Set-Cookie")
+		record := &IdempotencyRecord{
+			Status:      status,
+			Header:      header,
+			Body:        body.Bytes(),
+			Fingerprint: fingerprint,
+		}
+		if err := config.Store.Save(context.WithoutCancel(ctx), key, record, ttl); err != nil {
+			logError(c, "idempotency save error: %v", err)
+		}
+	}
+}
+
+// replayIdempotent 存在首次响应时重放，返回是否已处理
+func replayIdempotent(c *gin.Context, store IdempotencyStore, key, fingerprint string,
+	logError func(*gin.Context, string, error)) bool {
+	record, err := store.Get(c.Request.Context(), key)
+	if err != nil {
+		logError(c, "idempotency get error: %v", err)
+		response.Fail(c, errno.New(http.StatusServiceUnavailable).SetErrMsg("idempotency store unavailable"))
+		return true
+	}
+	if record == nil {
+		return false
+	}
+	if record.Fingerprint != fingerprint {
+		response.Fail(c, errno.New(http.StatusUnprocessableEntity).SetErrMsg("%s was used with a different request", IdempotencyKeyHeader))
+		return true
+	}
+
+	header := c.Writer.Header()
+	for k, v := range record.Header {
+		header[k] = v
+	}
+	header.Set(IdempotentReplayedHeader, "true")
+	c.Writer.WriteHeader(record.Status)
+	_, _ = c.Writer.Write(record.Body)
+	c.Abort()
+	return true
+}
+
+var errBodyTooLarge = errors.New("request body too large")
+
+// requestFingerprint 请求方法、路径及请求体的摘要，请求体超过 maxBodySize 时返回 errBodyTooLarge
+func requestFingerprint(c *gin.Context, maxBodySize int64) (string, error) {
+	h := sha256.New()
+	h.Write([]byte(c.Request.Method + " " + c.Request.URL.RequestURI() + "\n"))
+	if c.Request.Body != nil && c.Request.Body != http.NoBody {
+		body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxBodySize+1))
+		if err != nil {
+			return "", err
+		}
+		if int64(len(body)) > maxBodySize {
+			return "", errBodyTooLarge
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		h.Write(body)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+type memoryIdempotencyEntry struct {
+	record   *IdempotencyRecord
+	expireAt time.Time
+}
+
+type memoryIdempotencyLock struct {
+	token    string
+	expireAt time.Time
+}
+
+type memoryIdempotencyStore struct {
+	mu      sync.Mutex
+	locks   map[string]memoryIdempotencyLock
+	records map[string]memoryIdempotencyEntry
+}
+
+// NewMemoryIdempotencyStore 进程内存储，用于测试及单实例部署
+func NewMemoryIdempotencyStore() IdempotencyStore {
+	return &memoryIdempotencyStore{
+		locks:   make(map[string]memoryIdempotencyLock),
+		records: make(map[string]memoryIdempotencyEntry),
+	}
+}
+
+func (s *memoryIdempotencyStore) Lock(_ context.Context, key string, ttl time.Duration) (string, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if lock, ok := s.locks[key]; ok && time.Now().Before(lock.expireAt) {
+		return "", false, nil
+	}
+	token := uuid.New().String()
+	s.locks[key] = memoryIdempotencyLock{token: token, expireAt: time.Now().Add(ttl)}
+	return token, true, nil
+}
+
+func (s *memoryIdempotencyStore) Unlock(_ context.Context, key, token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if lock, ok := s.locks[key]; ok && lock.token == token {
+		delete(s.locks, key)
+	}
+	return nil
+}
+
+func (s *memoryIdempotencyStore) Get(_ context.Context, key string) (*IdempotencyRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.records[key]
+	if !ok {
+		return nil, nil
+	}
+	if time.Now().After(entry.expireAt) {
+		delete(s.records, key)
+		return nil, nil
+	}
+	return entry.record, nil
+}
+
+func (s *memoryIdempotencyStore) Save(_ context.Context, key string, record *IdempotencyRecord, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records[key] = memoryIdempotencyEntry{record: record, expireAt: time.Now().Add(ttl)}
+	return nil
+}
+
+// IdempotencyRedis 幂等存储使用的 Redis 客户端，redis.RedisCli 满足该接口
+type IdempotencyRedis interface {
+	SetWithData(key string, value interface{}, ttl time.Duration) error
+	SetNXWithError(key, value string, ttl time.Duration) (bool, error)
+	// Lookup 获取 key，不存在时返回 false 而不是错误
+	Lookup(key string) (value string, ok bool, err error)
+	Eval(script string, keys []string, args ...interface{}) (interface{}, error)
+}
+
+// unlockScript 锁的值与加锁时的 token 一致时才删除，避免删除超时后被其它请求持有的锁
+const unlockScript = `
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`
+
+type redisIdempotencyStore struct {
+	client IdempotencyRedis
+	prefix string
+}
+
+// NewRedisIdempotencyStore 使用 Redis 存储，响应保存在 prefix+key，锁为 prefix+key+":lock"
+func NewRedisIdempotencyStore(client IdempotencyRedis, prefix string) IdempotencyStore {
+	return &redisIdempotencyStore{client: client, prefix: prefix}
+}
+
+func (s *redisIdempotencyStore) Lock(_ context.Context, key string, ttl time.Duration) (string, bool, error) {
+	token := uuid.New().String()
+	locked, err := s.client.SetNXWithError(s.prefix+key+":lock", token, ttl)
+	if err != nil || !locked {
+		return "", false, err
+	}
+	return token, true, nil
+}
+
+func (s *redisIdempotencyStore) Unlock(_ context.Context, key, token string) error {
+	_, err := s.client.Eval(unlockScript, []string{s.prefix + key + ":lock"}, token)
+	return err
+}
+
+func (s *redisIdempotencyStore) Get(_ context.Context, key string) (*IdempotencyRecord, error) {
+	data, ok, err := s.client.Lookup(s.prefix + key)
+	if err != nil || !ok {
+		return nil, err
+	}
+	record := &IdempotencyRecord{}
+	if err := json.Unmarshal([]byte(data), record); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+func (s *redisIdempotencyStore) Save(_ context.Context, key string, record *IdempotencyRecord, ttl time.Duration) error {
+	return s.client.SetWithData(s.prefix+key, record, ttl)
+}
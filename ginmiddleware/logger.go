@@ -55,6 +55,11 @@ func (w bodyLogWriter) Write(b []byte) (int, error) {
 	return w.ResponseWriter.Write(b)
 }
 
+func (w bodyLogWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
 // LoggerMiddleware 请求日志中间件
 func LoggerMiddleware(config ...*LoggerConfig) gin.HandlerFunc {
 	var cfg *LoggerConfig
@@ -1,16 +1,40 @@
 package ginmiddleware
 
 import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/andybalholm/brotli"
 	"github.com/ffhuo/go-kits/common/field"
+	"github.com/ffhuo/go-kits/common/x509cert"
+	"github.com/ffhuo/go-kits/gout"
 	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 )
 
 // mockLogger 模拟日志实现
@@ -288,6 +312,32 @@ func TestRecoveryWithCustomHandler(t *testing.T) {
 	}
 }
 
+func TestRecoveryOnPanic(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	panics := 0
+	r := gin.New()
+	r.Use(Recovery(&RecoveryConfig{
+		Logger:  &mockLogger{},
+		OnPanic: func(c *gin.Context, err interface{}) { panics++ },
+	}))
+
+	r.GET("/panic", func(c *gin.Context) {
+		panic("test panic")
+	})
+
+	req, _ := http.NewRequest("GET", "/panic", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != 500 {
+		t.Errorf("Expected status 500 after panic, got %d", w.Code)
+	}
+	if panics != 1 {
+		t.Errorf("Expected OnPanic to be called once, got %d", panics)
+	}
+}
+
 func TestSkipPaths(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 
@@ -571,3 +621,1118 @@ func TestSimpleLoggerMiddleware(t *testing.T) {
 		t.Error("Simple logger should have recorded basic request information")
 	}
 }
+
+func TestTracing(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+
+	logger := &mockLogger{}
+	r := gin.New()
+	r.Use(Tracing(&TracingConfig{TracerProvider: tp}))
+	r.Use(SimpleLoggerMiddleware(logger))
+
+	var traceID string
+	r.GET("/users/:id", func(c *gin.Context) {
+		traceID = GetTraceID(c)
+		c.JSON(200, gin.H{"message": "ok"})
+	})
+
+	parent := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	req, _ := http.NewRequest("GET", "/users/1", nil)
+	req.Header.Set("traceparent", parent)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if traceID != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("Expected trace id from traceparent, got %s", traceID)
+	}
+
+	spans := exporter.GetSpans()
+	if len(spans) != 1 {
+		t.Fatalf("Expected 1 span, got %d", len(spans))
+	}
+	if spans[0].Name != "GET /users/:id" {
+		t.Errorf("Expected span name with route pattern, got %s", spans[0].Name)
+	}
+	if spans[0].Parent.SpanID().String() != "00f067aa0ba902b7" {
+		t.Errorf("Expected parent span id from traceparent, got %s", spans[0].Parent.SpanID())
+	}
+
+	if len(logger.logs) == 0 || !strings.Contains(logger.logs[0], "trace_id: "+traceID) {
+		t.Errorf("Log should contain trace_id field, got %v", logger.logs)
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	r := gin.New()
+	r.Use(RateLimit(&RateLimitConfig{
+		Limiter:    NewTokenBucketLimiter(1, 2),
+		Registerer: prometheus.NewRegistry(),
+	}))
+	r.GET("/test", func(c *gin.Context) {
+		c.JSON(200, gin.H{"message": "ok"})
+	})
+
+	for i := 0; i < 2; i++ {
+		req, _ := http.NewRequest("GET", "/test", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		if w.Code != 200 {
+			t.Fatalf("Request %d: expected status 200, got %d", i, w.Code)
+		}
+		if w.Header().Get("RateLimit-Limit") != "2" {
+			t.Errorf("Expected RateLimit-Limit 2, got %s", w.Header().Get("RateLimit-Limit"))
+		}
+		if w.Header().Get("RateLimit-Remaining") != fmt.Sprint(1-i) {
+			t.Errorf("Expected RateLimit-Remaining %d, got %s", 1-i, w.Header().Get("RateLimit-Remaining"))
+		}
+	}
+
+	req, _ := http.NewRequest("GET", "/test", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected status 429, got %d", w.Code)
+	}
+	if w.Header().Get("Retry-After") != "1" {
+		t.Errorf("Expected Retry-After 1, got %s", w.Header().Get("Retry-After"))
+	}
+	if !strings.Contains(w.Body.String(), `"code":429`) {
+		t.Errorf("Expected business error body, got %s", w.Body.String())
+	}
+
+	// 不同的键使用独立的配额
+	req, _ = http.NewRequest("GET", "/test", nil)
+	req.RemoteAddr = "10.0.0.2:1234"
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != 200 {
+		t.Errorf("Expected other client to pass, got %d", w.Code)
+	}
+}
+
+// mockScriptRunner 模拟 Redis 滑动窗口脚本
+type mockScriptRunner struct {
+	count int64
+	err   error
+}
+
+func (m *mockScriptRunner) Eval(script string, keys []string, args ...interface{}) (interface{}, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	// 时间由脚本读取 Redis 服务端时间，参数只有窗口、次数及随机数
+	if len(args) != 3 || !strings.Contains(script, "redis.call('TIME')") {
+		return nil, fmt.Errorf("unexpected script args %v", args)
+	}
+	limit := int64(args[1].(int))
+	if m.count < limit {
+		m.count++
+		return []interface{}{int64(1), limit - m.count, int64(60000)}, nil
+	}
+	return []interface{}{int64(0), int64(0), int64(1500)}, nil
+}
+
+func TestRedisRateLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	runner := &mockScriptRunner{}
+	logger := &mockLogger{}
+	r := gin.New()
+	r.Use(RateLimit(&RateLimitConfig{
+		Limiter:    NewRedisSlidingWindowLimiter(runner, 1, time.Minute, "ratelimit:"),
+		KeyFunc:    KeyByHeader("X-API-Key"),
+		Logger:     logger,
+		Registerer: prometheus.NewRegistry(),
+	}))
+	r.GET("/test", func(c *gin.Context) {
+		c.JSON(200, gin.H{"message": "ok"})
+	})
+
+	codes := make([]int, 0, 2)
+	for i := 0; i < 2; i++ {
+		req, _ := http.NewRequest("GET", "/test", nil)
+		req.Header.Set("X-API-Key", "key")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		codes = append(codes, w.Code)
+		if i == 1 && w.Header().Get("Retry-After") != "2" {
+			t.Errorf("Expected Retry-After 2, got %s", w.Header().Get("Retry-After"))
+		}
+	}
+	if codes[0] != 200 || codes[1] != http.StatusTooManyRequests {
+		t.Errorf("Expected [200 429], got %v", codes)
+	}
+
+	// Redis 不可用时放行
+	runner.err = fmt.Errorf("connection refused")
+	req, _ := http.NewRequest("GET", "/test", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != 200 {
+		t.Errorf("Expected fail open with status 200, got %d", w.Code)
+	}
+	if len(logger.logs) == 0 {
+		t.Error("Expected limiter error to be logged")
+	}
+}
+
+func TestJWTAuth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	secret := []byte("secret")
+	r := gin.New()
+	r.Use(JWTAuth(&JWTConfig{
+		KeySource: StaticKey(secret),
+		Issuer:    "go-kits",
+		SkipPaths: []string{"/public"},
+	}))
+	var subject string
+	r.GET("/test", func(c *gin.Context) {
+		subject, _ = GetClaims(c).GetSubject()
+		c.JSON(200, gin.H{"message": "ok"})
+	})
+	r.GET("/public", func(c *gin.Context) {
+		c.JSON(200, gin.H{"message": "ok"})
+	})
+
+	sign := func(method jwt.SigningMethod, key interface{}, claims jwt.MapClaims) string {
+		token, err := jwt.NewWithClaims(method, claims).SignedString(key)
+		if err != nil {
+			t.Fatalf("Failed to sign token: %v", err)
+		}
+		return token
+	}
+	valid := sign(jwt.SigningMethodHS256, secret, jwt.MapClaims{
+		"sub": "user-1", "iss": "go-kits", "exp": time.Now().Add(time.Hour).Unix(),
+	})
+	expired := sign(jwt.SigningMethodHS256, secret, jwt.MapClaims{
+		"sub": "user-1", "iss": "go-kits", "exp": time.Now().Add(-time.Hour).Unix(),
+	})
+	wrongKey := sign(jwt.SigningMethodHS256, []byte("other"), jwt.MapClaims{"sub": "user-1", "iss": "go-kits"})
+	noneAlg := sign(jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, jwt.MapClaims{"sub": "user-1", "iss": "go-kits"})
+
+	tests := []struct {
+		name   string
+		path   string
+		token  string
+		status int
+	}{
+		{"valid", "/test", valid, 200},
+		{"missing", "/test", "", 401},
+		{"expired", "/test", expired, 401},
+		{"wrong key", "/test", wrongKey, 401},
+		{"none alg", "/test", noneAlg, 401},
+		{"skip path", "/public", "", 200},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", tt.path, nil)
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tt.status {
+				t.Errorf("Expected status %d, got %d: %s", tt.status, w.Code, w.Body.String())
+			}
+		})
+	}
+
+	if subject != "user-1" {
+		t.Errorf("Expected subject user-1, got %s", subject)
+	}
+}
+
+func TestJWTAuthKeySources(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	certPEM, keyPEM, err := x509cert.Generate(time.Hour, nil)
+	if err != nil {
+		t.Fatalf("Failed to generate cert: %v", err)
+	}
+	block, _ := pem.Decode(keyPEM.Bytes())
+	rsaKey, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	if err != nil {
+		t.Fatalf("Failed to parse key: %v", err)
+	}
+	certFile := filepath.Join(t.TempDir(), "cert.pem")
+	if err := os.WriteFile(certFile, certPEM.Bytes(), 0600); err != nil {
+		t.Fatalf("Failed to write cert: %v", err)
+	}
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate ec key: %v", err)
+	}
+	jwks := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// 不支持的 OKP 密钥、未知曲线及对称密钥被忽略
+		fmt.Fprintf(w, `{"keys":[{"kty":"OKP","kid":"ed-1","crv":"Ed25519","x":"11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo"},`+
+			`{"kty":"EC","kid":"ec-2","crv":"secp256k1","x":"AA","y":"AA"},{"kty":"oct","kid":"hs-1","k":"c2VjcmV0"},`+
+			`{"kty":"EC","kid":"ec-1","use":"sig","crv":"P-256","x":"%s","y":"%s"}]}`,
+			base64.RawURLEncoding.EncodeToString(ecKey.X.FillBytes(make([]byte, 32))),
+			base64.RawURLEncoding.EncodeToString(ecKey.Y.FillBytes(make([]byte, 32))))
+	}))
+	defer jwks.Close()
+
+	pemSource, err := PEMFileKey(certFile)
+	if err != nil {
+		t.Fatalf("Failed to load pem key: %v", err)
+	}
+
+	rsToken, _ := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{"sub": "rsa"}).SignedString(rsaKey)
+	esToken := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.MapClaims{"sub": "ec"})
+	esToken.Header["kid"] = "ec-1"
+	esSigned, _ := esToken.SignedString(ecKey)
+	hsToken := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"sub": "hs"})
+	hsToken.Header["kid"] = "hs-1"
+	hsOct, _ := hsToken.SignedString([]byte("secret"))
+	// 使用公钥作为 HMAC 密钥伪造的 token 必须被拒绝
+	forged, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"sub": "rsa"}).SignedString(certPEM.Bytes())
+
+	tests := []struct {
+		name   string
+		source KeySource
+		token  string
+		status int
+	}{
+		{"pem rs256", pemSource, rsToken, 200},
+		{"pem forged hs256", pemSource, forged, 401},
+		{"jwks es256", JWKSKey(jwks.URL, time.Hour), esSigned, 200},
+		{"jwks unknown key", JWKSKey(jwks.URL, time.Hour), rsToken, 401},
+		{"jwks oct key ignored", JWKSKeyWithConfig(&JWKSConfig{URL: jwks.URL, Client: gout.NewClient(gout.WithTimeout(time.Second))}), hsOct, 401},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			r.Use(JWTAuth(&JWTConfig{KeySource: tt.source}))
+			r.GET("/test", func(c *gin.Context) {
+				c.JSON(200, gin.H{"message": "ok"})
+			})
+
+			req, _ := http.NewRequest("GET", "/test", nil)
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tt.status {
+				t.Errorf("Expected status %d, got %d: %s", tt.status, w.Code, w.Body.String())
+			}
+		})
+	}
+
+	unusable := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"keys":[{"kty":"oct","kid":"hs-1","k":"c2VjcmV0"}]}`)
+	}))
+	defer unusable.Close()
+	if _, err := JWKSKey(unusable.URL, time.Hour).Key(context.Background(), hsToken); err == nil || !strings.Contains(err.Error(), "no usable key") {
+		t.Errorf("Expected no usable key error, got %v", err)
+	}
+}
+
+// mockHashGetter 模拟 Redis hash
+type mockHashGetter map[string]map[string]string
+
+func (m mockHashGetter) HgetAll(key string) (map[string]string, error) {
+	return m[key], nil
+}
+
+func TestAPIKeyAuth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	stores := map[string]APIKeyStore{
+		"memory": NewMemoryAPIKeyStore(map[string]*APIKeyInfo{
+			"key-1": {ID: "app-1", Name: "app"},
+		}),
+		"redis": NewRedisAPIKeyStore(mockHashGetter{
+			"apikey:key-1": {"id": "app-1", "name": "app"},
+		}, "apikey:"),
+	}
+
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			logger := &mockLogger{}
+			r := gin.New()
+			r.Use(APIKeyAuth(&APIKeyConfig{Store: store}))
+			r.Use(SimpleLoggerMiddleware(logger))
+			var id string
+			r.GET("/test", func(c *gin.Context) {
+				id = GetAPIKeyInfo(c).ID
+				c.JSON(200, gin.H{"message": "ok"})
+			})
+
+			for key, status := range map[string]int{"key-1": 200, "key-2": 401, "": 401} {
+				req, _ := http.NewRequest("GET", "/test", nil)
+				req.Header.Set("X-API-Key", key)
+				w := httptest.NewRecorder()
+				r.ServeHTTP(w, req)
+				if w.Code != status {
+					t.Errorf("Key %q: expected status %d, got %d", key, status, w.Code)
+				}
+			}
+
+			if id != "app-1" {
+				t.Errorf("Expected api key id app-1, got %s", id)
+			}
+			if len(logger.logs) == 0 || !strings.Contains(logger.logs[0], "subject: app-1") {
+				t.Errorf("Log should contain subject field, got %v", logger.logs)
+			}
+		})
+	}
+}
+
+func TestCompress(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	large := strings.Repeat("hello compress ", 200)
+	r := gin.New()
+	r.Use(Compress())
+	r.GET("/large", func(c *gin.Context) {
+		c.String(200, large)
+	})
+	r.GET("/small", func(c *gin.Context) {
+		c.String(200, "small")
+	})
+	r.GET("/image", func(c *gin.Context) {
+		c.Data(200, "image/png", []byte(large))
+	})
+
+	decoders := map[string]func(io.Reader) (io.Reader, error){
+		"gzip": func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) },
+		"br":   func(r io.Reader) (io.Reader, error) { return brotli.NewReader(r), nil },
+		"deflate": func(r io.Reader) (io.Reader, error) {
+			return zlib.NewReader(r)
+		},
+	}
+	for encoding, decode := range decoders {
+		t.Run(encoding, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", "/large", nil)
+			req.Header.Set("Accept-Encoding", encoding)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Header().Get("Content-Encoding") != encoding {
+				t.Fatalf("Expected Content-Encoding %s, got %q", encoding, w.Header().Get("Content-Encoding"))
+			}
+			if w.Header().Get("Content-Length") != fmt.Sprint(w.Body.Len()) {
+				t.Errorf("Content-Length %s does not match body size %d", w.Header().Get("Content-Length"), w.Body.Len())
+			}
+			reader, err := decode(w.Body)
+			if err != nil {
+				t.Fatalf("Failed to create decoder: %v", err)
+			}
+			body, _ := io.ReadAll(reader)
+			if string(body) != large {
+				t.Error("Decompressed body does not match")
+			}
+		})
+	}
+
+	tests := []struct {
+		name   string
+		path   string
+		accept string
+		want   string
+	}{
+		{"prefer by q", "/large", "gzip;q=0.5, br;q=0.8", "br"},
+		{"same q uses server order", "/large", "gzip, br", "br"},
+		{"rejected", "/large", "gzip;q=0", ""},
+		{"small body", "/small", "gzip", ""},
+		{"content type not allowed", "/image", "gzip", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", tt.path, nil)
+			req.Header.Set("Accept-Encoding", tt.accept)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if got := w.Header().Get("Content-Encoding"); got != tt.want {
+				t.Errorf("Expected Content-Encoding %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestCompressRange(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	content := strings.Repeat("0123456789", 500)
+	r := gin.New()
+	r.Use(Compress())
+	r.GET("/file.txt", func(c *gin.Context) {
+		http.ServeContent(c.Writer, c.Request, "file.txt", time.Time{}, strings.NewReader(content))
+	})
+
+	tests := []struct {
+		name     string
+		rangeHdr string
+		status   int
+		encoding string
+		body     string
+	}{
+		{"full", "", 200, "gzip", content},
+		// 范围响应不压缩，Content-Range 与响应体一致
+		{"range", "bytes=100-2099", http.StatusPartialContent, "", content[100:2100]},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", "/file.txt", nil)
+			req.Header.Set("Accept-Encoding", "gzip")
+			if tt.rangeHdr != "" {
+				req.Header.Set("Range", tt.rangeHdr)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.status || w.Header().Get("Content-Encoding") != tt.encoding {
+				t.Fatalf("Expected %d with encoding %q, got %d %q", tt.status, tt.encoding, w.Code, w.Header().Get("Content-Encoding"))
+			}
+			body := w.Body.String()
+			if tt.encoding == "gzip" {
+				reader, err := gzip.NewReader(w.Body)
+				if err != nil {
+					t.Fatal(err)
+				}
+				data, _ := io.ReadAll(reader)
+				body = string(data)
+			}
+			if body != tt.body {
+				t.Errorf("Expected body of %d bytes, got %d", len(tt.body), len(body))
+			}
+		})
+	}
+}
+
+func TestCompressETag(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	r := gin.New()
+	r.Use(Compress())
+	r.GET("/test", func(c *gin.Context) {
+		c.JSON(200, gin.H{"message": "ok"})
+	})
+
+	req, _ := http.NewRequest("GET", "/test", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	etag := w.Header().Get("ETag")
+	if !strings.HasPrefix(etag, `W/"`) {
+		t.Fatalf("Expected weak ETag, got %q", etag)
+	}
+
+	req, _ = http.NewRequest("GET", "/test", nil)
+	req.Header.Set("If-None-Match", `"other", `+etag)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNotModified {
+		t.Errorf("Expected status 304, got %d", w.Code)
+	}
+	if w.Body.Len() != 0 {
+		t.Errorf("Expected empty body, got %s", w.Body.String())
+	}
+}
+
+func TestCompressStreaming(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	w := httptest.NewRecorder()
+	chunk := strings.Repeat("stream compress ", 128)
+	r := gin.New()
+	r.Use(Compress(&CompressConfig{MinSize: 1024, ETag: true, ETagMaxSize: 4096}))
+	r.GET("/image", func(c *gin.Context) {
+		c.Header("Content-Type", "image/png")
+		c.Writer.Write([]byte(chunk))
+		// 不可压缩的内容不经过缓存直接输出
+		if w.Body.Len() != len(chunk) {
+			t.Errorf("Expected image to be written through, got %d bytes", w.Body.Len())
+		}
+	})
+	r.GET("/large", func(c *gin.Context) {
+		c.Header("Content-Type", "text/plain")
+		for i := 0; i < 8; i++ {
+			c.Writer.Write([]byte(chunk))
+		}
+	})
+
+	req, _ := http.NewRequest("GET", "/image", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	r.ServeHTTP(w, req)
+	if w.Header().Get("Content-Encoding") != "" || w.Body.String() != chunk {
+		t.Errorf("Expected uncompressed image, got encoding %q", w.Header().Get("Content-Encoding"))
+	}
+
+	// 超过 ETagMaxSize 后流式压缩，不生成 ETag 与 Content-Length
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/large", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	r.ServeHTTP(w, req)
+	if w.Header().Get("Content-Encoding") != "gzip" || w.Header().Get("ETag") != "" || w.Header().Get("Content-Length") != "" {
+		t.Fatalf("Expected streamed gzip response, got %v", w.Header())
+	}
+	reader, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(reader)
+	if string(body) != strings.Repeat(chunk, 8) {
+		t.Errorf("Decompressed body does not match, got %d bytes", len(body))
+	}
+}
+
+func TestCompressWithBodyCapture(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	large := strings.Repeat("a", 2048)
+	captured := &bytes.Buffer{}
+	status := 0
+
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Next()
+		status = c.Writer.Status()
+	})
+	r.Use(Compress())
+	r.Use(func(c *gin.Context) {
+		c.Writer = bodyLogWriter{ResponseWriter: c.Writer, body: captured}
+		c.Next()
+	})
+	r.GET("/test", func(c *gin.Context) {
+		c.String(201, large)
+	})
+
+	req, _ := http.NewRequest("GET", "/test", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != 201 || status != 201 {
+		t.Errorf("Expected status 201, got %d and %d", w.Code, status)
+	}
+	if w.Header().Get("Content-Encoding") != "gzip" {
+		t.Errorf("Expected gzip response, got %q", w.Header().Get("Content-Encoding"))
+	}
+	if captured.String() != large {
+		t.Errorf("Body capturing writer should see uncompressed body, got %d bytes", captured.Len())
+	}
+}
+
+func TestBreaker(t *testing.T) {
+	var changes []string
+	b := NewBreaker("downstream", &BreakerConfig{
+		FailureRatio:     0.5,
+		MinRequests:      4,
+		CoolDown:         50 * time.Millisecond,
+		HalfOpenRequests: 1,
+		OnStateChange: func(name string, from, to BreakerState) {
+			changes = append(changes, from.String()+"->"+to.String())
+		},
+	})
+
+	failure := fmt.Errorf("downstream error")
+	for i := 0; i < 4; i++ {
+		_ = b.Execute(func() error {
+			if i%2 == 0 {
+				return failure
+			}
+			return nil
+		})
+	}
+	if b.State() != StateOpen {
+		t.Fatalf("Expected breaker to open, got %s", b.State())
+	}
+	if err := b.Execute(func() error { return nil }); err != ErrBreakerOpen {
+		t.Errorf("Expected ErrBreakerOpen, got %v", err)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if b.State() != StateHalfOpen {
+		t.Fatalf("Expected breaker to be half-open, got %s", b.State())
+	}
+
+	// 半开状态只放行一个探测请求
+	done, err := b.Allow()
+	if err != nil {
+		t.Fatalf("Expected probe to be allowed, got %v", err)
+	}
+	if _, err := b.Allow(); err != ErrBreakerOpen {
+		t.Errorf("Expected second probe to be rejected, got %v", err)
+	}
+	done(true)
+
+	if b.State() != StateClosed {
+		t.Errorf("Expected breaker to close, got %s", b.State())
+	}
+
+	want := []string{"closed->open", "open->half-open", "half-open->closed"}
+	if strings.Join(changes, ",") != strings.Join(want, ",") {
+		t.Errorf("Expected state changes %v, got %v", want, changes)
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	registry := prometheus.NewRegistry()
+	r := gin.New()
+	r.Use(CircuitBreaker(&CircuitBreakerConfig{
+		Breaker:    &BreakerConfig{MinRequests: 2, CoolDown: time.Minute},
+		Registerer: registry,
+	}))
+	r.GET("/fail", func(c *gin.Context) {
+		c.JSON(500, gin.H{"message": "error"})
+	})
+	r.GET("/ok", func(c *gin.Context) {
+		c.JSON(200, gin.H{"message": "ok"})
+	})
+
+	codes := make([]int, 0, 3)
+	for i := 0; i < 3; i++ {
+		req, _ := http.NewRequest("GET", "/fail", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		codes = append(codes, w.Code)
+
+		if i == 2 {
+			if w.Header().Get("Retry-After") == "" {
+				t.Error("Expected Retry-After header when breaker is open")
+			}
+			if !strings.Contains(w.Body.String(), `"code":503`) {
+				t.Errorf("Expected business error body, got %s", w.Body.String())
+			}
+		}
+	}
+	if fmt.Sprint(codes) != "[500 500 503]" {
+		t.Errorf("Expected [500 500 503], got %v", codes)
+	}
+
+	// 其它路由使用独立的熔断器
+	req, _ := http.NewRequest("GET", "/ok", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != 200 {
+		t.Errorf("Expected other route to pass, got %d", w.Code)
+	}
+
+	expected := `
+# HELP circuit_breaker_state The state of circuit breaker, 0: closed, 1: half-open, 2: open.
+# TYPE circuit_breaker_state gauge
+circuit_breaker_state{key="route:GET /fail"} 2
+circuit_breaker_state{key="route:GET /ok"} 0
+`
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expected), breakerStateGauge); err != nil {
+		t.Errorf("Expected open state gauge: %v", err)
+	}
+}
+
+func TestBulkhead(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	r := gin.New()
+	r.Use(CircuitBreaker(&CircuitBreakerConfig{MaxConcurrent: 1, Registerer: prometheus.NewRegistry()}))
+	r.GET("/slow", func(c *gin.Context) {
+		close(started)
+		<-release
+		c.JSON(200, gin.H{"message": "ok"})
+	})
+
+	first := httptest.NewRecorder()
+	finished := make(chan struct{})
+	go func() {
+		req, _ := http.NewRequest("GET", "/slow", nil)
+		r.ServeHTTP(first, req)
+		close(finished)
+	}()
+	<-started
+
+	req, _ := http.NewRequest("GET", "/slow", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503, got %d", w.Code)
+	}
+
+	close(release)
+	<-finished
+	if first.Code != 200 {
+		t.Errorf("Expected first request to succeed, got %d", first.Code)
+	}
+}
+
+// mockIdempotencyRedis 模拟 redis.RedisCli
+type mockIdempotencyRedis struct {
+	mu   sync.Mutex
+	data map[string]string
+	err  error
+}
+
+func (m *mockIdempotencyRedis) SetWithData(key string, value interface{}, ttl time.Duration) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.data[key] = string(data)
+	return nil
+}
+
+func (m *mockIdempotencyRedis) SetNXWithError(key, value string, ttl time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.err != nil {
+		return false, m.err
+	}
+	if _, ok := m.data[key]; ok {
+		return false, nil
+	}
+	m.data[key] = value
+	return true, nil
+}
+
+func (m *mockIdempotencyRedis) Lookup(key string) (string, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.err != nil {
+		return "", false, m.err
+	}
+	value, ok := m.data[key]
+	return value, ok, nil
+}
+
+// Eval 只实现 unlockScript
+func (m *mockIdempotencyRedis) Eval(script string, keys []string, args ...interface{}) (interface{}, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.data[keys[0]] != args[0] {
+		return int64(0), nil
+	}
+	delete(m.data, keys[0])
+	return int64(1), nil
+}
+
+func TestIdempotency(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	stores := map[string]IdempotencyStore{
+		"memory": NewMemoryIdempotencyStore(),
+		"redis":  NewRedisIdempotencyStore(&mockIdempotencyRedis{data: map[string]string{}}, "idempotency:"),
+	}
+
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			calls := 0
+			r := gin.New()
+			r.Use(Idempotency(&IdempotencyConfig{Store: store}))
+			r.POST("/orders", func(c *gin.Context) {
+				calls++
+				c.Header("Location", fmt.Sprintf("/orders/%d", calls))
+				c.JSON(201, gin.H{"id": calls})
+			})
+
+			send := func(key, body string) *httptest.ResponseRecorder {
+				req, _ := http.NewRequest("POST", "/orders", strings.NewReader(body))
+				req.Header.Set(IdempotencyKeyHeader, key)
+				w := httptest.NewRecorder()
+				r.ServeHTTP(w, req)
+				return w
+			}
+
+			first := send("key-1", `{"item":"a"}`)
+			retry := send("key-1", `{"item":"a"}`)
+
+			if calls != 1 {
+				t.Errorf("Expected handler to be called once, got %d", calls)
+			}
+			if retry.Code != 201 || retry.Body.String() != first.Body.String() {
+				t.Errorf("Expected replayed response %d %s, got %d %s", first.Code, first.Body.String(), retry.Code, retry.Body.String())
+			}
+			if retry.Header().Get("Location") != "/orders/1" || retry.Header().Get(IdempotentReplayedHeader) != "true" {
+				t.Errorf("Expected replayed headers, got %v", retry.Header())
+			}
+
+			if w := send("key-1", `{"item":"b"}`); w.Code != http.StatusUnprocessableEntity {
+				t.Errorf("Expected status 422 for different body, got %d", w.Code)
+			}
+			if w := send("key-2", `{"item":"a"}`); w.Code != 201 || calls != 2 {
+				t.Errorf("Expected new key to be processed, got %d", w.Code)
+			}
+		})
+	}
+}
+
+func TestIdempotencyInFlight(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	r := gin.New()
+	r.Use(Idempotency(&IdempotencyConfig{Store: NewMemoryIdempotencyStore()}))
+	r.POST("/orders", func(c *gin.Context) {
+		close(started)
+		<-release
+		c.JSON(201, gin.H{"id": 1})
+	})
+
+	newRequest := func() *http.Request {
+		req, _ := http.NewRequest("POST", "/orders", nil)
+		req.Header.Set(IdempotencyKeyHeader, "key-1")
+		return req
+	}
+
+	first := httptest.NewRecorder()
+	finished := make(chan struct{})
+	go func() {
+		r.ServeHTTP(first, newRequest())
+		close(finished)
+	}()
+	<-started
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, newRequest())
+	if w.Code != http.StatusConflict {
+		t.Errorf("Expected status 409 while first request is in flight, got %d", w.Code)
+	}
+
+	close(release)
+	<-finished
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, newRequest())
+	if w.Code != 201 || w.Header().Get(IdempotentReplayedHeader) != "true" {
+		t.Errorf("Expected replayed response after first request finished, got %d", w.Code)
+	}
+}
+
+func TestIdempotencyRedisLock(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	client := &mockIdempotencyRedis{data: map[string]string{}}
+	store := NewRedisIdempotencyStore(client, "idempotency:")
+	ctx := context.Background()
+
+	// 第一个持有者超时后锁被其它请求获取，第一个持有者解锁不能删除新锁
+	stale, locked, err := store.Lock(ctx, "key-1", time.Second)
+	if err != nil || !locked {
+		t.Fatalf("Expected lock to be acquired, got %v %v", locked, err)
+	}
+	client.Eval(unlockScript, []string{"idempotency:key-1:lock"}, stale)
+	current, locked, _ := store.Lock(ctx, "key-1", time.Second)
+	if !locked {
+		t.Fatal("Expected lock to be acquired after release")
+	}
+	if err := store.Unlock(ctx, "key-1", stale); err != nil {
+		t.Fatal(err)
+	}
+	if client.data["idempotency:key-1:lock"] != current {
+		t.Errorf("Expected stale unlock to keep the current lock")
+	}
+
+	client.err = errors.New("connection refused")
+	r := gin.New()
+	r.Use(Idempotency(&IdempotencyConfig{Store: store}))
+	r.POST("/orders", func(c *gin.Context) {
+		c.JSON(201, gin.H{"id": 1})
+	})
+	req, _ := http.NewRequest("POST", "/orders", nil)
+	req.Header.Set(IdempotencyKeyHeader, "key-2")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503 when redis is unavailable, got %d", w.Code)
+	}
+}
+
+func TestIdempotencyMaxBodySize(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var received string
+	r := gin.New()
+	r.Use(Idempotency(&IdempotencyConfig{Store: NewMemoryIdempotencyStore(), MaxBodySize: 8}))
+	r.POST("/orders", func(c *gin.Context) {
+		body, _ := io.ReadAll(c.Request.Body)
+		received = string(body)
+		c.JSON(201, gin.H{"id": 1})
+	})
+
+	tests := []struct {
+		key    string
+		body   string
+		status int
+	}{
+		{"key-1", "12345678", 201},
+		{"key-2", "123456789", http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		received = ""
+		req, _ := http.NewRequest("POST", "/orders", strings.NewReader(tt.body))
+		req.Header.Set(IdempotencyKeyHeader, tt.key)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != tt.status {
+			t.Errorf("Expected status %d for %d bytes, got %d", tt.status, len(tt.body), w.Code)
+		}
+		// 处理函数仍能读取完整的请求体
+		if tt.status == 201 && received != tt.body {
+			t.Errorf("Expected handler to read %q, got %q", tt.body, received)
+		}
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	handlerDone := make(chan error, 1)
+	r := gin.New()
+	r.Use(RequestID())
+	r.Use(Timeout(50*time.Millisecond, &TimeoutConfig{
+		Routes: map[string]time.Duration{"GET /slow-allowed": time.Second},
+	}))
+	r.GET("/slow", func(c *gin.Context) {
+		<-c.Request.Context().Done()
+		time.Sleep(10 * time.Millisecond)
+		// 超时后的写入被丢弃
+		_, err := c.Writer.WriteString("late")
+		handlerDone <- err
+	})
+	r.GET("/slow-allowed", func(c *gin.Context) {
+		time.Sleep(100 * time.Millisecond)
+		c.JSON(200, gin.H{"message": "ok"})
+	})
+	r.GET("/fast", func(c *gin.Context) {
+		if _, ok := c.Request.Context().Deadline(); !ok {
+			t.Error("Expected request context to have a deadline")
+		}
+		c.Header("X-Custom", "value")
+		c.JSON(201, gin.H{"message": "ok"})
+	})
+
+	req, _ := http.NewRequest("GET", "/slow", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusGatewayTimeout {
+		t.Errorf("Expected status 504, got %d", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), `"code":504`) || !strings.Contains(w.Body.String(), `"requestId":"`+w.Header().Get("X-Request-ID")+`"`) {
+		t.Errorf("Expected error envelope with request id, got %s", w.Body.String())
+	}
+	if err := <-handlerDone; err != http.ErrHandlerTimeout {
+		t.Errorf("Expected write after timeout to fail with ErrHandlerTimeout, got %v", err)
+	}
+
+	req, _ = http.NewRequest("GET", "/slow-allowed", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != 200 {
+		t.Errorf("Expected route override to allow slow handler, got %d", w.Code)
+	}
+
+	req, _ = http.NewRequest("GET", "/fast", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != 201 || w.Header().Get("X-Custom") != "value" || !strings.Contains(w.Body.String(), "ok") {
+		t.Errorf("Expected buffered response to be written, got %d %v %s", w.Code, w.Header(), w.Body.String())
+	}
+}
+
+func TestTimeoutHandlerIgnoresContext(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	release := make(chan struct{})
+	handlerDone := make(chan struct{})
+	r := gin.New()
+	r.Use(Timeout(50 * time.Millisecond))
+	r.GET("/blocking", func(c *gin.Context) {
+		defer close(handlerDone)
+		// 不感知 context 的处理函数
+		<-release
+		c.String(200, "late")
+	})
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+	var once sync.Once
+	unblock := func() { once.Do(func() { close(release) }) }
+	// 未及时发送 504 时处理函数 2s 后返回，避免测试阻塞
+	time.AfterFunc(2*time.Second, unblock)
+	defer func() {
+		unblock()
+		<-handlerDone
+	}()
+
+	start := time.Now()
+	resp, err := http.Get(srv.URL + "/blocking")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected 504 to be sent when the deadline fires, got it after %s", elapsed)
+	}
+	if resp.StatusCode != http.StatusGatewayTimeout || !strings.Contains(string(body), `"code":504`) {
+		t.Errorf("Expected 504 envelope, got %d %s", resp.StatusCode, body)
+	}
+}
+
+func TestRateLimitSharedRegistry(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	registry := prometheus.NewRegistry()
+	r := gin.New()
+	// 重复创建中间件时复用已注册的指标，不会 panic
+	r.Use(RateLimit(&RateLimitConfig{Limiter: NewTokenBucketLimiter(1, 1), Registerer: registry}))
+	r.Use(RateLimit(&RateLimitConfig{Limiter: NewTokenBucketLimiter(100, 100), Registerer: registry}))
+	r.GET("/test", func(c *gin.Context) {
+		c.JSON(200, gin.H{"message": "ok"})
+	})
+
+	for i := 0; i < 2; i++ {
+		req, _ := http.NewRequest("GET", "/test", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+	}
+
+	if n := testutil.CollectAndCount(registry, "http_rate_limit_rejected_total"); n != 1 {
+		t.Errorf("Expected 1 rejected series, got %d", n)
+	}
+}
+
+func TestRateLimitConflictingCollector(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	// 同名指标 label 不一致时注册失败，直接 panic 而不是返回未导出的指标
+	registry.MustRegister(prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_rate_limit_rejected_total",
+		Help: "conflicting",
+	}, []string{"route"}))
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected panic on conflicting collector")
+		}
+	}()
+	RateLimit(&RateLimitConfig{Limiter: NewTokenBucketLimiter(1, 1), Registerer: registry})
+}
+
+func TestCircuitBreakerConflictingCollector(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: breakerStateGauge, Help: "conflicting"}, []string{"name"}))
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected panic on conflicting collector")
+		}
+	}()
+	CircuitBreaker(&CircuitBreakerConfig{Registerer: registry})
+}
@@ -0,0 +1,284 @@
+package ginmiddleware
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ffhuo/go-kits/common/errno"
+	"github.com/ffhuo/go-kits/common/metrics"
+	"github.com/ffhuo/go-kits/ginmiddleware/response"
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// RateLimitResult 一次限流判断的结果
+type RateLimitResult struct {
+	Allowed    bool          // 是否放行
+	Limit      int           // 窗口内允许的请求数
+	Remaining  int           // 窗口内剩余的请求数
+	Reset      time.Duration // 距离配额完全恢复的时间
+	RetryAfter time.Duration // 被拒绝时建议的重试等待时间
+}
+
+// Limiter 限流器
+type Limiter interface {
+	Allow(ctx context.Context, key string) (*RateLimitResult, error)
+}
+
+// KeyFunc 从请求中提取限流的键
+type KeyFunc func(c *gin.Context) string
+
+// KeyByIP 按客户端IP限流
+func KeyByIP() KeyFunc {
+	return func(c *gin.Context) string {
+		return "ip:" + c.ClientIP()
+	}
+}
+
+// KeyByHeader 按请求头限流，例如 X-API-Key
+func KeyByHeader(name string) KeyFunc {
+	return func(c *gin.Context) string {
+		return "header:" + c.GetHeader(name)
+	}
+}
+
+// KeyByRoute 按路由限流，同一路由模式共享配额
+func KeyByRoute() KeyFunc {
+	return func(c *gin.Context) string {
+		return "route:" + c.Request.Method + " " + routeOf(c)
+	}
+}
+
+// RateLimitConfig 限流中间件配置
+type RateLimitConfig struct {
+	Limiter    Limiter               // 限流器，必填
+	KeyFunc    KeyFunc               // 限流的键，默认按IP
+	SkipPaths  []string              // 跳过限流的路径
+	Logger     Logger                // 限流器出错时记录日志，出错时放行请求
+	Registerer prometheus.Registerer // 拒绝次数指标的注册器，默认 prometheus.DefaultRegisterer
+}
+
+// RateLimit 限流中间件，设置 RateLimit-Limit、RateLimit-Remaining、RateLimit-Reset 响应头，
+// 超出限制时返回 429 及 Retry-After
+func RateLimit(config *RateLimitConfig) gin.HandlerFunc {
+	if config == nil || config.Limiter == nil {
+		panic("Limiter is required for RateLimit middleware")
+	}
+	keyFunc := config.KeyFunc
+	if keyFunc == nil {
+		keyFunc = KeyByIP()
+	}
+	rejected := rateLimitRejectedCounter(config.Registerer)
+
+	return func(c *gin.Context) {
+		if contains(config.SkipPaths, c.Request.URL.Path) {
+			c.Next()
+			return
+		}
+
+		result, err := config.Limiter.Allow(c.Request.Context(), keyFunc(c))
+		if err != nil {
+			if config.Logger != nil {
+				config.Logger.Error(c, "rate limiter error: %v", err)
+			}
+			c.Next()
+			return
+		}
+
+		c.Header("RateLimit-Limit", strconv.Itoa(result.Limit))
+		c.Header("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		c.Header("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset)))
+
+		if !result.Allowed {
+			rejected.WithLabelValues(routeOf(c)).Inc()
+			c.Header("Retry-After", strconv.Itoa(ceilSeconds(result.RetryAfter)))
+			response.Fail(c, errno.New(http.StatusTooManyRequests).SetErrMsg("too many requests"))
+			return
+		}
+
+		c.Next()
+	}
+}
+
+func rateLimitRejectedCounter(reg prometheus.Registerer) *prometheus.CounterVec {
+	return metrics.MustRegister(reg, prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_rate_limit_rejected_total",
+		Help: "The total number of requests rejected by rate limiter.",
+	}, []string{"route"}))
+}
+
+// routeOf 返回路由模式，未匹配路由时返回 unmatched，避免指标基数膨胀
+func routeOf(c *gin.Context) string {
+	if route := c.FullPath(); route != "" {
+		return route
+	}
+	return "unmatched"
+}
+
+func ceilSeconds(d time.Duration) int {
+	if d <= 0 {
+		return 0
+	}
+	return int(math.Ceil(d.Seconds()))
+}
+
+// tokenBucketLimiter 进程内令牌桶限流器
+type tokenBucketLimiter struct {
+	rate  float64 // 每秒生成的令牌数
+	burst int     // 桶容量
+
+	mu          sync.Mutex
+	buckets     map[string]*tokenBucket
+	lastCleanup time.Time
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// NewTokenBucketLimiter 创建进程内令牌桶限流器，每秒生成 rate 个令牌，最多累积 burst 个
+func NewTokenBucketLimiter(rate float64, burst int) Limiter {
+	if rate <= 0 || burst <= 0 {
+		panic("rate and burst must be positive")
+	}
+	return &tokenBucketLimiter{
+		rate:        rate,
+		burst:       burst,
+		buckets:     make(map[string]*tokenBucket),
+		lastCleanup: time.Now(),
+	}
+}
+
+func (l *tokenBucketLimiter) Allow(_ context.Context, key string) (*RateLimitResult, error) {
+	now := time.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.cleanup(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: float64(l.burst), last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(float64(l.burst), b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+
+	result := &RateLimitResult{Limit: l.burst}
+	if b.tokens >= 1 {
+		b.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = l.duration(1 - b.tokens)
+	}
+	result.Remaining = int(b.tokens)
+	result.Reset = l.duration(float64(l.burst) - b.tokens)
+	return result, nil
+}
+
+func (l *tokenBucketLimiter) duration(tokens float64) time.Duration {
+	return time.Duration(tokens / l.rate * float64(time.Second))
+}
+
+// cleanup 定期清理已经补满的桶，避免按IP等维度限流时内存持续增长
+func (l *tokenBucketLimiter) cleanup(now time.Time) {
+	if now.Sub(l.lastCleanup) < time.Minute {
+		return
+	}
+	l.lastCleanup = now
+
+	full := l.duration(float64(l.burst))
+	for key, b := range l.buckets {
+		if now.Sub(b.last) > full {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// ScriptRunner 执行 Lua 脚本的 Redis 客户端，redis.RedisCli 满足该接口
+type ScriptRunner interface {
+	Eval(script string, keys []string, args ...interface{}) (interface{}, error)
+}
+
+// slidingWindowScript 基于 zset 的滑动窗口，返回 {是否放行, 剩余次数, 需要等待的毫秒数}。
+// 使用 Redis 服务端时间，各实例的时钟偏差不影响共享的窗口
+const slidingWindowScript = `
+redis.replicate_commands()
+local key = KEYS[1]
+local window = tonumber(ARGV[1])
+local limit = tonumber(ARGV[2])
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+
+redis.call('ZREMRANGEBYSCORE', key, 0, now - window)
+local count = redis.call('ZCARD', key)
+if count < limit then
+	redis.call('ZADD', key, now, now .. '-' .. ARGV[3])
+	redis.call('PEXPIRE', key, window)
+	return {1, limit - count - 1, window}
+end
+
+local retry = window
+local oldest = redis.call('ZRANGE', key, 0, 0, 'WITHSCORES')
+if oldest[2] then
+	retry = tonumber(oldest[2]) + window - now
+end
+return {0, 0, retry}
+`
+
+// redisSlidingWindowLimiter 基于 Redis 的分布式滑动窗口限流器
+type redisSlidingWindowLimiter struct {
+	client ScriptRunner
+	limit  int
+	window time.Duration
+	prefix string
+}
+
+// NewRedisSlidingWindowLimiter 创建分布式滑动窗口限流器，window 时间内最多允许 limit 次请求，
+// prefix 为 Redis 键前缀
+func NewRedisSlidingWindowLimiter(client ScriptRunner, limit int, window time.Duration, prefix string) Limiter {
+	if limit <= 0 || window <= 0 {
+		panic("limit and window must be positive")
+	}
+	return &redisSlidingWindowLimiter{
+		client: client,
+		limit:  limit,
+		window: window,
+		prefix: prefix,
+	}
+}
+
+func (l *redisSlidingWindowLimiter) Allow(_ context.Context, key string) (*RateLimitResult, error) {
+	res, err := l.client.Eval(slidingWindowScript, []string{l.prefix + key},
+		l.window.Milliseconds(), l.limit, rand.Int63())
+	if err != nil {
+		return nil, err
+	}
+
+	values, ok := res.([]interface{})
+	if !ok || len(values) != 3 {
+		return nil, fmt.Errorf("unexpected rate limit script result: %v", res)
+	}
+	allowed, _ := values[0].(int64)
+	remaining, _ := values[1].(int64)
+	wait, _ := values[2].(int64)
+
+	result := &RateLimitResult{
+		Allowed:   allowed == 1,
+		Limit:     l.limit,
+		Remaining: int(remaining),
+		Reset:     time.Duration(wait) * time.Millisecond,
+	}
+	if !result.Allowed {
+		result.RetryAfter = result.Reset
+	}
+	return result, nil
+}
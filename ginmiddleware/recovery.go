@@ -18,6 +18,7 @@ type RecoveryConfig struct {
 	StackTraceSize   int                             // 堆栈跟踪大小
 	CustomHandler    func(*gin.Context, interface{}) // 自定义错误处理器
 	PrintStack       bool                            // 是否打印堆栈到控制台
+	OnPanic          func(*gin.Context, interface{}) // panic 回调，例如调用 prometheus.PanicInc 计数
 }
 
 // DefaultRecoveryConfig 默认Recovery配置
@@ -66,6 +67,10 @@ func Recovery(config ...*RecoveryConfig) gin.HandlerFunc {
 				// 记录错误日志
 				logPanicError(cfg.Logger, c.Request.Context(), errorMsg, requestInfo, stack, cfg.PrintStack)
 
+				if cfg.OnPanic != nil {
+					cfg.OnPanic(c, err)
+				}
+
 				// 如果有自定义处理器，使用自定义处理器
 				if cfg.CustomHandler != nil {
 					cfg.CustomHandler(c, err)
@@ -0,0 +1,194 @@
+package response
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/ffhuo/go-kits/common/errno"
+	"github.com/ffhuo/go-kits/common/paginator"
+	"github.com/gin-gonic/gin"
+)
+
+// CodeOK 成功时的业务码
+const CodeOK = 0
+
+var (
+	// DefaultErrorStatus 未注册且不是 4xx/5xx 的业务码对应的 HTTP 状态码
+	DefaultErrorStatus = http.StatusBadRequest
+	// DefaultLanguage 请求未携带 Accept-Language 或没有对应语言的文案时使用的语言
+	DefaultLanguage = "zh"
+)
+
+// Body 统一响应结构
+type Body struct {
+	Code      int         `json:"code"`
+	Msg       string      `json:"msg"`
+	Data      interface{} `json:"data,omitempty"`
+	RequestID string      `json:"requestId,omitempty"`
+}
+
+// PageData 分页响应的 data
+type PageData struct {
+	List     interface{} `json:"list"`
+	Total    int64       `json:"total"`
+	Page     int64       `json:"page"`
+	PageSize int64       `json:"pageSize"`
+}
+
+var (
+	mu       sync.RWMutex
+	statuses = map[int]int{}
+	messages = map[string]map[int]string{}
+)
+
+// RegisterStatus 注册业务码对应的 HTTP 状态码
+func RegisterStatus(code, status int) {
+	mu.Lock()
+	defer mu.Unlock()
+	statuses[code] = status
+}
+
+// RegisterMessages 注册某种语言下业务码的文案，文案可包含 fmt 占位符，使用 BusinessError.ErrArgs 格式化
+func RegisterMessages(lang string, msgs map[int]string) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	lang = strings.ToLower(lang)
+	if messages[lang] == nil {
+		messages[lang] = make(map[int]string, len(msgs))
+	}
+	for code, msg := range msgs {
+		messages[lang][code] = msg
+	}
+}
+
+// Status 返回业务码对应的 HTTP 状态码
+func Status(code int) int {
+	mu.RLock()
+	status, ok := statuses[code]
+	mu.RUnlock()
+	if ok {
+		return status
+	}
+
+	switch {
+	case code == CodeOK:
+		return http.StatusOK
+	case code >= 400 && code < 600:
+		return code
+	case code < 0:
+		return http.StatusInternalServerError
+	}
+	return DefaultErrorStatus
+}
+
+// Message 按请求的 Accept-Language 返回错误文案
+func Message(c *gin.Context, err *errno.BusinessError) string {
+	if format, ok := lookupMessage(c.GetHeader("Accept-Language"), err.Code); ok {
+		if args := err.ErrArgs(); len(args) > 0 {
+			return fmt.Sprintf(format, args...)
+		}
+		return format
+	}
+	if err.Msg != "" {
+		return err.Msg
+	}
+	return http.StatusText(Status(err.Code))
+}
+
+func lookupMessage(acceptLanguage string, code int) (string, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	for _, lang := range parseLanguages(acceptLanguage) {
+		if msg, ok := messages[lang][code]; ok {
+			return msg, true
+		}
+	}
+	msg, ok := messages[strings.ToLower(DefaultLanguage)][code]
+	return msg, ok
+}
+
+// parseLanguages 解析 Accept-Language，zh-CN 会依次尝试 zh-cn、zh
+func parseLanguages(header string) []string {
+	var langs []string
+	for _, part := range strings.Split(header, ",") {
+		lang := strings.ToLower(strings.TrimSpace(strings.SplitN(part, ";", 2)[0]))
+		if lang == "" || lang == "*" {
+			continue
+		}
+		langs = append(langs, lang)
+		if i := strings.IndexByte(lang, '-'); i > 0 {
+			langs = append(langs, lang[:i])
+		}
+	}
+	return langs
+}
+
+// RequestID 从上下文获取请求ID，兼容 ginmiddleware.RequestID 与 core 生成的请求ID
+func RequestID(c *gin.Context) string {
+	if id := c.GetString("request_id"); id != "" {
+		return id
+	}
+	return c.GetString("requestId")
+}
+
+// OK 返回成功响应
+func OK(c *gin.Context, data interface{}) {
+	c.JSON(http.StatusOK, Body{
+		Code:      CodeOK,
+		Msg:       "success",
+		Data:      data,
+		RequestID: RequestID(c),
+	})
+}
+
+// Fail 返回错误响应，err 链中包含 BusinessError 时使用其业务码与文案；
+// 其它错误按 code -1 处理，只返回通用文案，原始错误通过 c.Error 记录，由日志中间件输出
+func Fail(c *gin.Context, err error) {
+	if err == nil {
+		OK(c, nil)
+		return
+	}
+
+	var berr *errno.BusinessError
+	if !errors.As(err, &berr) {
+		if len(c.Errors) == 0 || c.Errors.Last().Err != err {
+			_ = c.Error(err)
+		}
+		berr = &errno.BusinessError{Code: -1}
+	}
+	c.AbortWithStatusJSON(Status(berr.Code), Body{
+		Code:      berr.Code,
+		Msg:       Message(c, berr),
+		RequestID: RequestID(c),
+	})
+}
+
+// Page 返回分页响应
+func Page(c *gin.Context, list interface{}, total int64, p *paginator.Paginator) {
+	data := PageData{
+		List:  list,
+		Total: total,
+	}
+	if p != nil {
+		data.Page = p.Page()
+		data.PageSize = p.PageSize()
+	}
+	OK(c, data)
+}
+
+// ErrorHandler 处理函数通过 c.Error 记录错误且未写响应时，将最后一个错误渲染为统一错误响应
+func ErrorHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		if len(c.Errors) == 0 || c.Writer.Written() {
+			return
+		}
+		Fail(c, c.Errors.Last().Err)
+	}
+}
@@ -0,0 +1,151 @@
+package response
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ffhuo/go-kits/common/errno"
+	"github.com/ffhuo/go-kits/common/paginator"
+	"github.com/gin-gonic/gin"
+)
+
+func doRequest(r *gin.Engine, header map[string]string) (*httptest.ResponseRecorder, Body) {
+	req, _ := http.NewRequest("GET", "/test", nil)
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	var body Body
+	json.Unmarshal(w.Body.Bytes(), &body)
+	return w, body
+}
+
+func TestOK(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	r := gin.New()
+	r.GET("/test", func(c *gin.Context) {
+		c.Set("request_id", "req-1")
+		OK(c, gin.H{"name": "go-kits"})
+	})
+
+	w, body := doRequest(r, nil)
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", w.Code)
+	}
+	if body.Code != CodeOK || body.RequestID != "req-1" {
+		t.Errorf("Unexpected body: %+v", body)
+	}
+}
+
+func TestFailWithMessages(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	RegisterStatus(10001, http.StatusNotFound)
+	RegisterMessages("zh", map[int]string{10001: "用户 %s 不存在"})
+	RegisterMessages("en", map[int]string{10001: "user %s not found"})
+
+	r := gin.New()
+	r.GET("/test", func(c *gin.Context) {
+		Fail(c, errno.NewWithArgs(10001, "tom"))
+	})
+
+	w, body := doRequest(r, map[string]string{"Accept-Language": "en-US,en;q=0.9"})
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404, got %d", w.Code)
+	}
+	if body.Msg != "user tom not found" {
+		t.Errorf("Expected english message, got %s", body.Msg)
+	}
+
+	_, body = doRequest(r, nil)
+	if body.Msg != "用户 tom 不存在" {
+		t.Errorf("Expected default language message, got %s", body.Msg)
+	}
+}
+
+func TestErrorHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	r := gin.New()
+	r.Use(ErrorHandler())
+	r.GET("/test", func(c *gin.Context) {
+		c.Set("requestId", "req-2")
+		c.Error(errors.New("db down"))
+	})
+
+	w, body := doRequest(r, nil)
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("Expected status 500, got %d", w.Code)
+	}
+	// 非业务错误不向客户端暴露原始错误信息
+	if body.Code != -1 || body.Msg != http.StatusText(http.StatusInternalServerError) || body.RequestID != "req-2" {
+		t.Errorf("Unexpected body: %+v", body)
+	}
+}
+
+func TestFailWrappedError(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var logged []string
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Next()
+		logged = c.Errors.Errors()
+	})
+	r.GET("/wrapped", func(c *gin.Context) {
+		Fail(c, fmt.Errorf("create order: %w", errno.New(http.StatusConflict).SetErrMsg("order exists")))
+	})
+	r.GET("/internal", func(c *gin.Context) {
+		Fail(c, errors.New("pq: relation \"orders\" does not exist"))
+	})
+
+	req, _ := http.NewRequest("GET", "/wrapped", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	var body Body
+	json.Unmarshal(w.Body.Bytes(), &body)
+	if w.Code != http.StatusConflict || body.Code != http.StatusConflict || body.Msg != "order exists" {
+		t.Errorf("Expected wrapped business error, got %d %+v", w.Code, body)
+	}
+
+	req, _ = http.NewRequest("GET", "/internal", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	body = Body{}
+	json.Unmarshal(w.Body.Bytes(), &body)
+	if w.Code != http.StatusInternalServerError || body.Msg != http.StatusText(http.StatusInternalServerError) {
+		t.Errorf("Expected generic message, got %d %+v", w.Code, body)
+	}
+	if len(logged) != 1 || !strings.Contains(logged[0], "pq: relation") {
+		t.Errorf("Expected original error to be recorded for logging, got %v", logged)
+	}
+}
+
+func TestPage(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	r := gin.New()
+	r.GET("/test", func(c *gin.Context) {
+		Page(c, []int{1, 2}, 12, paginator.NewPaginator(2, 2))
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/test", nil)
+	r.ServeHTTP(w, req)
+
+	var body struct {
+		Data PageData `json:"data"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &body)
+	if body.Data.Total != 12 || body.Data.Page != 2 || body.Data.PageSize != 2 {
+		t.Errorf("Unexpected page data: %+v", body.Data)
+	}
+}
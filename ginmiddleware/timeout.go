@@ -0,0 +1,251 @@
+package ginmiddleware
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ffhuo/go-kits/common/errno"
+	"github.com/ffhuo/go-kits/ginmiddleware/response"
+	"github.com/gin-gonic/gin"
+)
+
+// TimeoutConfig 超时中间件配置
+type TimeoutConfig struct {
+	Routes    map[string]time.Duration // 按路由覆盖超时时间，键为 "GET /users/:id" 或 "/users/:id"，<=0 表示不限制
+	SkipPaths []string                 // 不限制超时的路径
+}
+
+// Timeout 超时中间件，为请求 context 设置 deadline，超时后返回 504，处理函数之后的写入会被丢弃。
+// 处理函数在独立的 goroutine 中执行，需要通过 c.Request.Context() 感知超时，使用 core 时
+// gin.Context 本身也会携带 deadline，可直接传给 sqldb.DB.Read/Write 及 gout.DataFlow.WithContext。
+func Timeout(d time.Duration, config ...*TimeoutConfig) gin.HandlerFunc {
+	cfg := &TimeoutConfig{}
+	if len(config) > 0 && config[0] != nil {
+		cfg = config[0]
+	}
+
+	timeoutOf := func(c *gin.Context) time.Duration {
+		route := c.FullPath()
+		if t, ok := cfg.Routes[c.Request.Method+" "+route]; ok {
+			return t
+		}
+		if t, ok := cfg.Routes[route]; ok {
+			return t
+		}
+		return d
+	}
+
+	return func(c *gin.Context) {
+		timeout := timeoutOf(c)
+		if timeout <= 0 || contains(cfg.SkipPaths, c.Request.URL.Path) {
+			c.Next()
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+
+		// 超时响应在处理函数运行前生成，超时后不再访问 gin.Context
+		berr := errno.New(http.StatusGatewayTimeout).SetErrMsg("request timeout")
+		timeoutBody, _ := json.Marshal(response.Body{
+			Code:      berr.Code,
+			Msg:       response.Message(c, berr),
+			RequestID: response.RequestID(c),
+		})
+
+		w := c.Writer
+		tw := &timeoutWriter{ResponseWriter: w, header: w.Header().Clone(), status: http.StatusOK}
+		c.Writer = tw
+
+		var panicked interface{}
+		done := make(chan struct{})
+		go func() {
+			defer func() {
+				panicked = recover()
+				close(done)
+			}()
+			c.Next()
+		}()
+
+		select {
+		case <-done:
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				tw.timeout(response.Status(berr.Code), timeoutBody)
+			}
+			// 等待处理函数返回，gin.Context 回收后不能再被使用
+			<-done
+		}
+
+		c.Writer = w
+		if panicked != nil {
+			panic(panicked)
+		}
+		tw.flush()
+	}
+}
+
+// timeoutWriter 缓存处理函数的响应，超时后丢弃处理函数的写入
+type timeoutWriter struct {
+	gin.ResponseWriter
+
+	mu          sync.Mutex
+	header      http.Header
+	buf         bytes.Buffer
+	status      int
+	written     bool
+	passthrough bool // 处理函数调用过 Flush，响应已开始输出
+	timedOut    bool
+}
+
+func (w *timeoutWriter) Header() http.Header {
+	if w.passthrough {
+		return w.ResponseWriter.Header()
+	}
+	return w.header
+}
+
+func (w *timeoutWriter) WriteHeader(code int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.timedOut {
+		return
+	}
+	if w.passthrough {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	if code > 0 {
+		w.status = code
+	}
+}
+
+func (w *timeoutWriter) WriteHeaderNow() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.passthrough && !w.timedOut {
+		w.ResponseWriter.WriteHeaderNow()
+		return
+	}
+	w.written = true
+}
+
+func (w *timeoutWriter) Write(b []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	if w.passthrough {
+		return w.ResponseWriter.Write(b)
+	}
+	w.written = true
+	return w.buf.Write(b)
+}
+
+func (w *timeoutWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *timeoutWriter) Status() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.passthrough {
+		return w.ResponseWriter.Status()
+	}
+	return w.status
+}
+
+func (w *timeoutWriter) Size() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.passthrough {
+		return w.ResponseWriter.Size()
+	}
+	if !w.written {
+		return -1
+	}
+	return w.buf.Len()
+}
+
+func (w *timeoutWriter) Written() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.passthrough {
+		return w.ResponseWriter.Written()
+	}
+	return w.written
+}
+
+// Flush 流式响应直接输出，此后超时只取消 context，不再返回 504
+func (w *timeoutWriter) Flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.timedOut {
+		return
+	}
+	if !w.passthrough {
+		w.commit()
+		w.passthrough = true
+	}
+	w.ResponseWriter.Flush()
+}
+
+func (w *timeoutWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.passthrough = true
+	return w.ResponseWriter.Hijack()
+}
+
+// timeout 输出超时响应
+func (w *timeoutWriter) timeout(status int, body []byte) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.passthrough {
+		return
+	}
+	w.timedOut = true
+
+	header := w.ResponseWriter.Header()
+	header.Set("Content-Type", "application/json; charset=utf-8")
+	header.Set("Content-Length", strconv.Itoa(len(body)))
+	w.ResponseWriter.WriteHeader(status)
+	_, _ = w.ResponseWriter.Write(body)
+	// 立即发送给客户端，不必等待忽略 context 的处理函数返回
+	w.ResponseWriter.Flush()
+}
+
+// flush 处理函数正常返回后输出缓存的响应
+func (w *timeoutWriter) flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.timedOut || w.passthrough {
+		return
+	}
+	w.commit()
+}
+
+func (w *timeoutWriter) commit() {
+	header := w.ResponseWriter.Header()
+	for k, v := range w.header {
+		header[k] = v
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+	if w.written {
+		w.ResponseWriter.WriteHeaderNow()
+	}
+	if w.buf.Len() > 0 {
+		_, _ = w.ResponseWriter.Write(w.buf.Bytes())
+		w.buf.Reset()
+	}
+}
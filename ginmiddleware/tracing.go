@@ -0,0 +1,106 @@
+package ginmiddleware
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/ffhuo/go-kits/common/field"
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// TracerName 中间件创建 span 使用的 tracer 名称
+	TracerName = "github.com/ffhuo/go-kits/ginmiddleware"
+	// TraceIDKey trace id 在日志字段中的键名
+	TraceIDKey = "trace_id"
+	// SpanIDKey span id 在日志字段中的键名
+	SpanIDKey = "span_id"
+)
+
+// TracingConfig 链路追踪中间件配置
+type TracingConfig struct {
+	TracerProvider trace.TracerProvider          // 默认使用 otel.GetTracerProvider()
+	Propagator     propagation.TextMapPropagator // 默认使用 W3C traceparent/baggage
+	SkipPaths      []string                      // 跳过追踪的路径
+}
+
+// DefaultTracingConfig 默认链路追踪配置
+func DefaultTracingConfig() *TracingConfig {
+	return &TracingConfig{
+		Propagator: propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}),
+	}
+}
+
+// Tracing 链路追踪中间件，从请求头提取 traceparent 并创建服务端 span，
+// trace_id、span_id 会写入日志字段，请求 context 携带 span 供下游 gout、sqldb 使用
+func Tracing(config ...*TracingConfig) gin.HandlerFunc {
+	cfg := DefaultTracingConfig()
+	if len(config) > 0 && config[0] != nil {
+		cfg = config[0]
+	}
+	if cfg.Propagator == nil {
+		cfg.Propagator = DefaultTracingConfig().Propagator
+	}
+
+	return func(c *gin.Context) {
+		if contains(cfg.SkipPaths, c.Request.URL.Path) {
+			c.Next()
+			return
+		}
+
+		tp := cfg.TracerProvider
+		if tp == nil {
+			tp = otel.GetTracerProvider()
+		}
+
+		route := c.FullPath()
+		if route == "" {
+			route = c.Request.URL.Path
+		}
+
+		ctx := cfg.Propagator.Extract(c.Request.Context(), propagation.HeaderCarrier(c.Request.Header))
+		ctx, span := tp.Tracer(TracerName).Start(ctx, c.Request.Method+" "+route,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.request.method", c.Request.Method),
+				attribute.String("http.route", route),
+				attribute.String("url.path", c.Request.URL.Path),
+				attribute.String("client.address", c.ClientIP()),
+				attribute.String("user_agent.original", c.Request.UserAgent()),
+			),
+		)
+		defer span.End()
+
+		c.Request = c.Request.WithContext(ctx)
+		if sc := span.SpanContext(); sc.IsValid() {
+			field.With(c, field.F(TraceIDKey, sc.TraceID().String()), field.F(SpanIDKey, sc.SpanID().String()))
+		}
+
+		c.Next()
+
+		status := c.Writer.Status()
+		span.SetAttributes(attribute.Int("http.response.status_code", status))
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+		if len(c.Errors) > 0 {
+			span.RecordError(c.Errors.Last().Err)
+		}
+	}
+}
+
+// GetTraceID 从 context 获取当前 span 的 trace id
+func GetTraceID(ctx context.Context) string {
+	if c, ok := ctx.(*gin.Context); ok && c.Request != nil {
+		ctx = c.Request.Context()
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		return sc.TraceID().String()
+	}
+	return ""
+}
@@ -0,0 +1,136 @@
+package gout
+
+import (
+	"net/http"
+	"time"
+)
+
+// Client 可复用的 HTTP 客户端，创建后配置不再变化，可在多个 goroutine 间共享。
+// 每次调用 GET/POST 等方法都会返回独立的 DataFlow。
+type Client struct {
+	baseURL      string
+	header       map[string]string
+	timeout      time.Duration
+	transport    http.RoundTripper
+	retry        *RetryPolicy
+	interceptors []Interceptor
+
+	client *http.Client
+	// err 配置错误，由该 Client 创建的 DataFlow 直接返回
+	err error
+}
+
+// Option Client 配置项
+type Option func(*Client)
+
+// WithBaseURL 设置 base URL，请求地址为相对路径时拼接在其后
+func WithBaseURL(baseURL string) Option {
+	return func(c *Client) {
+		c.baseURL = baseURL
+	}
+}
+
+// WithHeader 设置默认请求头，可被单个请求的 AddHeader/SetHeader 覆盖
+func WithHeader(key, value string) Option {
+	return func(c *Client) {
+		c.header[key] = value
+	}
+}
+
+// WithTimeout 设置默认超时时间，可被单个请求的 SetTimeout 覆盖
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Client) {
+		c.timeout = timeout
+	}
+}
+
+// WithTransport 设置底层 http.RoundTripper
+func WithTransport(transport http.RoundTripper) Option {
+	return func(c *Client) {
+		c.transport = transport
+	}
+}
+
+// WithHTTPClient 使用已有的 http.Client，同时设置 WithTransport 时会复制一份再替换 Transport
+func WithHTTPClient(client *http.Client) Option {
+	return func(c *Client) {
+		c.client = client
+	}
+}
+
+// WithRetry 设置默认重试策略，可被单个请求的 Retry 覆盖
+func WithRetry(policy *RetryPolicy) Option {
+	return func(c *Client) {
+		c.retry = policy
+	}
+}
+
+// WithInterceptors 添加拦截器，在全局拦截器之内、单个请求的拦截器之外执行
+func WithInterceptors(interceptors ...Interceptor) Option {
+	return func(c *Client) {
+		c.interceptors = append(c.interceptors, interceptors...)
+	}
+}
+
+// NewClient 创建 Client，未指定 http.Client 时创建独立实例，不会修改 http.DefaultClient
+func NewClient(opts ...Option) *Client {
+	c := &Client{header: map[string]string{}}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	switch {
+	case c.client == nil:
+		c.client = &http.Client{Transport: c.transport}
+	case c.transport != nil:
+		client := *c.client
+		client.Transport = c.transport
+		c.client = &client
+	}
+	return c
+}
+
+// New 创建一个使用该 Client 配置的 DataFlow
+func (c *Client) New() *DataFlow {
+	return &DataFlow{Client: c.client, owner: c, Err: c.err}
+}
+
+// GET send HTTP GET method
+func (c *Client) GET(url string) *DataFlow {
+	return c.New().GET(url)
+}
+
+// POST send HTTP POST method
+func (c *Client) POST(url string) *DataFlow {
+	return c.New().POST(url)
+}
+
+// PUT send HTTP PUT method
+func (c *Client) PUT(url string) *DataFlow {
+	return c.New().PUT(url)
+}
+
+// DELETE send HTTP DELETE method
+func (c *Client) DELETE(url string) *DataFlow {
+	return c.New().DELETE(url)
+}
+
+// PATCH send HTTP PATCH method
+func (c *Client) PATCH(url string) *DataFlow {
+	return c.New().PATCH(url)
+}
+
+// HEAD send HTTP HEAD method
+func (c *Client) HEAD(url string) *DataFlow {
+	return c.New().HEAD(url)
+}
+
+// OPTIONS send HTTP OPTIONS method
+func (c *Client) OPTIONS(url string) *DataFlow {
+	return c.New().OPTIONS(url)
+}
+
+// Method send HTTP request with any method
+func (c *Client) Method(method, url string) *DataFlow {
+	return c.New().Method(method, url)
+}
@@ -0,0 +1,190 @@
+package gout
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"strings"
+	"sync"
+
+	"github.com/ffhuo/go-kits/gout/decode"
+	"gopkg.in/yaml.v2"
+)
+
+// Codec 请求/响应 body 的编解码器，通过 RegisterCodec 按 Content-Type 注册，可扩展 protobuf、msgpack、CBOR 等格式
+type Codec interface {
+	// ContentType 编码请求体时使用的 Content-Type
+	ContentType() string
+	Encode(w io.Writer, v interface{}) error
+	Decode(r io.Reader, v interface{}) error
+}
+
+var (
+	codecMu sync.RWMutex
+	codecs  = map[string]Codec{}
+	// codecTypes 注册顺序，用于生成 Accept 请求头
+	codecTypes []string
+)
+
+func init() {
+	RegisterCodec(jsonCodec{}, "text/json")
+	RegisterCodec(xmlCodec{}, "text/xml")
+	RegisterCodec(yamlCodec{}, "application/x-yaml", "text/yaml")
+}
+
+// RegisterCodec 注册编解码器，codec.ContentType() 及 contentTypes 中的媒体类型都会映射到 codec，已注册的类型会被覆盖
+func RegisterCodec(codec Codec, contentTypes ...string) {
+	codecMu.Lock()
+	defer codecMu.Unlock()
+	for _, contentType := range append([]string{codec.ContentType()}, contentTypes...) {
+		mediaType := parseMediaType(contentType)
+		if _, ok := codecs[mediaType]; !ok {
+			codecTypes = append(codecTypes, mediaType)
+		}
+		codecs[mediaType] = codec
+	}
+}
+
+// LookupCodec 按 Content-Type 查找编解码器，未直接注册的 application/xxx+json 等结构化后缀类型按后缀匹配
+func LookupCodec(contentType string) (Codec, bool) {
+	mediaType := parseMediaType(contentType)
+
+	codecMu.RLock()
+	defer codecMu.RUnlock()
+	if codec, ok := codecs[mediaType]; ok {
+		return codec, true
+	}
+	if i := strings.LastIndex(mediaType, "+"); i >= 0 {
+		codec, ok := codecs["application/"+mediaType[i+1:]]
+		return codec, ok
+	}
+	return nil, false
+}
+
+// acceptHeader 由已注册的媒体类型生成 Accept 请求头
+func acceptHeader() string {
+	codecMu.RLock()
+	defer codecMu.RUnlock()
+	return strings.Join(codecTypes, ", ")
+}
+
+func parseMediaType(contentType string) string {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = strings.TrimSpace(strings.SplitN(contentType, ";", 2)[0])
+	}
+	return strings.ToLower(mediaType)
+}
+
+// SetBodyWith 使用 contentType 对应的编解码器编码 data 作为请求体
+func (d *DataFlow) SetBodyWith(contentType string, data interface{}) *DataFlow {
+	codec, ok := LookupCodec(contentType)
+	if !ok {
+		d.Err = fmt.Errorf("gout: no codec registered for %q", contentType)
+		return d
+	}
+	d.bodyEncoder = &codecEncoder{codec: codec, contentType: contentType, data: data}
+	return d
+}
+
+// Bind 根据响应的 Content-Type 选择编解码器解码到 res，Content-Type 为空、text/plain 或 application/octet-stream 时按 JSON 解码。
+// 传入 errRes 时非 2xx 响应解码到 errRes[0]，否则与 2xx 一样解码到 res。
+func (d *DataFlow) Bind(res interface{}, errRes ...interface{}) *DataFlow {
+	return d.bind(newCodecDecoder(res), newCodecDecoder(first(errRes)))
+}
+
+// codecEncoder 通过 Codec 编码请求体
+type codecEncoder struct {
+	codec       Codec
+	contentType string
+	data        interface{}
+}
+
+func (c *codecEncoder) Encode(w io.Writer) error {
+	return c.codec.Encode(w, c.data)
+}
+
+func (c *codecEncoder) Add(interface{}) error {
+	return errors.New("Not Support Add codec data")
+}
+
+func (c *codecEncoder) Name() string {
+	return "codec"
+}
+
+func (c *codecEncoder) ContentType() string {
+	return c.contentType
+}
+
+// codecDecoder 根据响应 Content-Type 选择 Codec 解码
+type codecDecoder struct {
+	obj interface{}
+}
+
+func newCodecDecoder(obj interface{}) decode.Decoder {
+	if obj == nil {
+		return nil
+	}
+	return &codecDecoder{obj: obj}
+}
+
+func (c *codecDecoder) Decode(r io.Reader) error {
+	return c.decodeAs(r, "")
+}
+
+func (c *codecDecoder) Value() interface{} {
+	return c.obj
+}
+
+func (c *codecDecoder) decodeAs(r io.Reader, contentType string) error {
+	codec, ok := LookupCodec(contentType)
+	if !ok && isGenericContentType(contentType) {
+		codec, ok = jsonCodec{}, true
+	}
+	if !ok {
+		return fmt.Errorf("gout: no codec registered for %q", contentType)
+	}
+	return codec.Decode(r, c.obj)
+}
+
+// isGenericContentType 未声明具体格式的 Content-Type，很多接口以此返回 JSON
+func isGenericContentType(contentType string) bool {
+	switch parseMediaType(contentType) {
+	case "", "text/plain", "application/octet-stream":
+		return true
+	}
+	return false
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) ContentType() string { return "application/json" }
+
+func (jsonCodec) Encode(w io.Writer, v interface{}) error { return json.NewEncoder(w).Encode(v) }
+
+func (jsonCodec) Decode(r io.Reader, v interface{}) error { return json.NewDecoder(r).Decode(v) }
+
+type xmlCodec struct{}
+
+func (xmlCodec) ContentType() string { return "application/xml" }
+
+func (xmlCodec) Encode(w io.Writer, v interface{}) error { return xml.NewEncoder(w).Encode(v) }
+
+func (xmlCodec) Decode(r io.Reader, v interface{}) error { return xml.NewDecoder(r).Decode(v) }
+
+type yamlCodec struct{}
+
+func (yamlCodec) ContentType() string { return "application/yaml" }
+
+func (yamlCodec) Encode(w io.Writer, v interface{}) error {
+	encoder := yaml.NewEncoder(w)
+	if err := encoder.Encode(v); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+func (yamlCodec) Decode(r io.Reader, v interface{}) error { return yaml.NewDecoder(r).Decode(v) }
@@ -0,0 +1,231 @@
+package gout
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// csvCodec 测试用的编解码器，按逗号拆分字符串
+type csvCodec struct{}
+
+func (csvCodec) ContentType() string { return "text/csv" }
+
+func (csvCodec) Encode(w io.Writer, v interface{}) error {
+	_, err := io.WriteString(w, strings.Join(v.([]string), ","))
+	return err
+}
+
+func (csvCodec) Decode(r io.Reader, v interface{}) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	*v.(*[]string) = strings.Split(string(data), ",")
+	return nil
+}
+
+func TestLookupCodec(t *testing.T) {
+	tests := []struct {
+		contentType string
+		want        Codec
+		ok          bool
+	}{
+		{"application/json", jsonCodec{}, true},
+		{"Application/JSON; charset=utf-8", jsonCodec{}, true},
+		{"text/json", jsonCodec{}, true},
+		{"application/problem+json", jsonCodec{}, true},
+		{"application/vnd.api+json; charset=utf-8", jsonCodec{}, true},
+		{"application/atom+xml", xmlCodec{}, true},
+		{"text/yaml", yamlCodec{}, true},
+		{"application/x-yaml", yamlCodec{}, true},
+		{"application/vnd.custom+unknown", nil, false},
+		{"text/html", nil, false},
+		{"", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.contentType, func(t *testing.T) {
+			codec, ok := LookupCodec(tt.contentType)
+			if ok != tt.ok || codec != tt.want {
+				t.Fatalf("expected %T %v, got %T %v", tt.want, tt.ok, codec, ok)
+			}
+		})
+	}
+}
+
+func TestBind(t *testing.T) {
+	RegisterCodec(csvCodec{})
+	defer func() {
+		codecMu.Lock()
+		delete(codecs, "text/csv")
+		codecTypes = codecTypes[:len(codecTypes)-1]
+		codecMu.Unlock()
+	}()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentType := r.URL.Query().Get("type")
+		body := r.URL.Query().Get("body")
+		switch r.URL.Path {
+		case "/echo":
+			contentType = r.Header.Get("Content-Type")
+			data, _ := io.ReadAll(r.Body)
+			body = string(data)
+		case "/accept":
+			body = fmt.Sprintf("%q", r.Header.Get("Accept"))
+		}
+		if contentType != "" {
+			w.Header().Set("Content-Type", contentType)
+		}
+		io.WriteString(w, body)
+	}))
+	defer srv.Close()
+
+	type item struct {
+		Name string `json:"name" xml:"name" yaml:"name"`
+	}
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		want        string
+	}{
+		{"json", "application/json", `{"name":"a"}`, "a"},
+		{"problem json", "application/problem+json", `{"name":"b"}`, "b"},
+		{"xml", "application/xml", `<item><name>c</name></item>`, "c"},
+		{"yaml", "text/yaml; charset=utf-8", "name: d", "d"},
+		// 未声明具体格式时按 JSON 解码
+		{"no content type", "", `{"name":"e"}`, "e"},
+		{"text plain", "text/plain", `{"name":"f"}`, "f"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var res item
+			_, err := New().GET(srv.URL).SetQuery(map[string]string{"type": tt.contentType, "body": tt.body}).Bind(&res).Do()
+			if err != nil || res.Name != tt.want {
+				t.Fatalf("expected %q, got %+v %v", tt.want, res, err)
+			}
+		})
+	}
+
+	var res item
+	if _, err := New().GET(srv.URL).SetQuery(map[string]string{"type": "text/html", "body": "<p>"}).Bind(&res).Do(); err == nil || !strings.Contains(err.Error(), "no codec") {
+		t.Fatalf("expected no codec error, got %v", err)
+	}
+
+	var accept string
+	if _, err := New().GET(srv.URL + "/accept").Bind(&accept).Do(); err != nil || !strings.HasPrefix(accept, "application/json, text/json, application/xml") || !strings.HasSuffix(accept, "text/csv") {
+		t.Fatalf("unexpected Accept header %q %v", accept, err)
+	}
+
+	// 通过注册的 Codec 编码请求体
+	var echoed []string
+	if _, err := New().POST(srv.URL+"/echo").SetBodyWith("text/csv; charset=utf-8", []string{"a", "b"}).Bind(&echoed).Do(); err != nil || strings.Join(echoed, "|") != "a|b" {
+		t.Fatalf("unexpected echo %q %v", echoed, err)
+	}
+	if err := New().POST(srv.URL).SetBodyWith("application/x-unknown", nil).Err; err == nil {
+		t.Fatal("expected error for unknown codec")
+	}
+}
+
+func TestBindErrorBody(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ok":
+			w.Header().Set("Content-Type", "application/json")
+			io.WriteString(w, `{"name":"a"}`)
+		case "/empty":
+			w.WriteHeader(http.StatusInternalServerError)
+		case "/xml":
+			w.Header().Set("Content-Type", "application/xml")
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, `<error><code>404</code><message>missing</message></error>`)
+		default:
+			w.Header().Set("Content-Type", "application/problem+json")
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, `{"code":400,"message":"bad"}`)
+		}
+	}))
+	defer srv.Close()
+
+	type result struct {
+		Name string `json:"name"`
+	}
+	type apiError struct {
+		Code    int    `json:"code" xml:"code"`
+		Message string `json:"message" xml:"message"`
+	}
+	tests := []struct {
+		name   string
+		bind   func(d *DataFlow, res *result, errRes *apiError) *DataFlow
+		path   string
+		status int
+		res    result
+		errRes apiError
+	}{
+		{"success", func(d *DataFlow, res *result, errRes *apiError) *DataFlow { return d.Bind(res, errRes) },
+			"/ok", http.StatusOK, result{Name: "a"}, apiError{}},
+		{"bind", func(d *DataFlow, res *result, errRes *apiError) *DataFlow { return d.Bind(res, errRes) },
+			"/bad", http.StatusBadRequest, result{}, apiError{400, "bad"}},
+		{"bind json", func(d *DataFlow, res *result, errRes *apiError) *DataFlow { return d.BindJSON(res, errRes) },
+			"/bad", http.StatusBadRequest, result{}, apiError{400, "bad"}},
+		{"bind xml", func(d *DataFlow, res *result, errRes *apiError) *DataFlow { return d.Bind(res, errRes) },
+			"/xml", http.StatusNotFound, result{}, apiError{404, "missing"}},
+		// 错误 body 为空时不报错
+		{"empty error body", func(d *DataFlow, res *result, errRes *apiError) *DataFlow { return d.BindJSON(res, errRes) },
+			"/empty", http.StatusInternalServerError, result{}, apiError{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				res    result
+				errRes apiError
+			)
+			code, err := tt.bind(New().GET(srv.URL+tt.path), &res, &errRes).Do()
+			if err != nil || code != tt.status {
+				t.Fatalf("expected %d, got %d %v", tt.status, code, err)
+			}
+			if res != tt.res || errRes != tt.errRes {
+				t.Fatalf("expected %+v %+v, got %+v %+v", tt.res, tt.errRes, res, errRes)
+			}
+		})
+	}
+
+	// 未传 errRes 时非 2xx 响应与 2xx 一样解码到 res
+	var raw map[string]interface{}
+	if _, err := New().GET(srv.URL + "/bad").BindJSON(&raw).Do(); err != nil || raw["message"] != "bad" {
+		t.Fatalf("expected error body decoded into res, got %v %v", raw, err)
+	}
+
+	// ExpectSuccess 时仍会解码错误 body
+	var errRes apiError
+	resp, err := New().GET(srv.URL+"/bad").Bind(&raw, &errRes).ExpectSuccess().DoResponse()
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || errRes.Message != "bad" || resp == nil || !json.Valid(resp.Body) {
+		t.Fatalf("expected status error with decoded body, got %v %+v", err, errRes)
+	}
+}
+
+// SetJSON 传入原始 JSON 时原样发送，非法 JSON 返回错误（曾经判断条件写反，合法 JSON 反而报错）
+func TestSetJSONRaw(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(w, r.Body)
+	}))
+	defer srv.Close()
+
+	var got map[string]int
+	if _, err := New().POST(srv.URL).SetJSON([]byte(`{"a":1}`)).BindJSON(&got).Do(); err != nil {
+		t.Fatal(err)
+	}
+	if got["a"] != 1 {
+		t.Fatalf("expected raw body, got %v", got)
+	}
+
+	if _, err := New().POST(srv.URL).SetJSON([]byte(`{invalid`)).Do(); err == nil || !strings.Contains(err.Error(), "Not json data") {
+		t.Fatalf("expected invalid json error, got %v", err)
+	}
+}
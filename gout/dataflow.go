@@ -3,24 +3,38 @@ package gout
 import (
 	"bytes"
 	"context"
+	"errors"
 	"io"
-	"log"
 	"net/http"
 	"net/url"
-	"sync"
+	"strings"
 	"time"
 
 	"github.com/ffhuo/go-kits/gout/decode"
 	"github.com/ffhuo/go-kits/gout/encode"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 )
 
 var defaultTimeout time.Duration = 10 * time.Second
 
-// DataFlow controls core data structure of http request
+const tracerName = "github.com/ffhuo/go-kits/gout"
+
+// propagator 向请求头注入 W3C traceparent/baggage
+var propagator = propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+
+// DataFlow controls core data structure of http request.
+// DataFlow 保存单次请求的状态，不能在多个 goroutine 间共享，并发场景使用 Client 为每个请求创建 DataFlow。
 type DataFlow struct {
 	debug bool
 	*http.Client
 
+	// 创建该 DataFlow 的 Client，提供 base URL、默认请求头等配置
+	owner *Client
+
 	c   context.Context
 	Err error
 
@@ -32,6 +46,21 @@ type DataFlow struct {
 	// http body
 	bodyEncoder encode.Encoder
 	bodyDecoder decode.Decoder
+	// 非 2xx 响应的解码器，为 nil 时与 2xx 一样使用 bodyDecoder
+	errDecoder decode.Decoder
+
+	// 流式请求体，streamSize 小于 0 时使用 chunked 传输
+	stream      io.Reader
+	streamSize  int64
+	streamStart int64
+
+	// 本次请求的超时时间，为 0 时依次使用 Client、http.Client 的超时时间及 defaultTimeout
+	timeout time.Duration
+	// streaming 为 true 时未显式设置超时不限制读取时长
+	streaming bool
+
+	uploadProgress   ProgressFunc
+	downloadProgress ProgressFunc
 
 	queryEncoder encode.Encoder
 
@@ -43,9 +72,18 @@ type DataFlow struct {
 
 	req *http.Request
 
-	cancel context.CancelFunc
+	// 重试策略，为 nil 时只请求一次
+	retry *RetryPolicy
+
+	// 本次请求的拦截器
+	interceptors []Interceptor
+
+	// 校验响应状态码，为 nil 时不校验
+	expect func(status int) bool
 
 	resp *http.Response
+	// 最近一次请求的响应信息，Reset 不会清除
+	last *Response
 }
 
 func New(c ...*http.Client) *DataFlow {
@@ -60,19 +98,30 @@ func New(c ...*http.Client) *DataFlow {
 }
 
 func (d *DataFlow) Reset() {
-	if d.cancel != nil {
-		d.cancel()
-	}
 	d.Err = nil
+	if d.owner != nil {
+		d.Err = d.owner.err
+	}
 	d.method = ""
 	d.url = ""
 	d.bodyDecoder = nil
+	d.errDecoder = nil
 	d.bodyEncoder = nil
 	d.queryEncoder = nil
+	d.stream = nil
+	d.streamSize = 0
+	d.streamStart = 0
+	d.timeout = 0
+	d.streaming = false
+	d.uploadProgress = nil
+	d.downloadProgress = nil
 
 	d.headerEncoder = nil
 	d.cookies = nil
 	d.req = nil
+	d.retry = nil
+	d.expect = nil
+	d.interceptors = nil
 	d.resp = nil
 }
 
@@ -100,6 +149,32 @@ func (d *DataFlow) DELETE(url string) *DataFlow {
 	return d
 }
 
+func (d *DataFlow) PATCH(url string) *DataFlow {
+	d.method = http.MethodPatch
+	d.url = url
+	return d
+}
+
+func (d *DataFlow) HEAD(url string) *DataFlow {
+	d.method = http.MethodHead
+	d.url = url
+	return d
+}
+
+func (d *DataFlow) OPTIONS(url string) *DataFlow {
+	d.method = http.MethodOptions
+	d.url = url
+	return d
+}
+
+// Method 使用任意 HTTP 方法，如 PROPFIND、PURGE 等
+func (d *DataFlow) Method(method, url string) *DataFlow {
+	d.method = method
+	d.url = url
+	return d
+}
+
+// Debug 通过 SetDebugLogger 设置的日志实例记录请求与响应
 func (d *DataFlow) Debug() *DataFlow {
 	d.debug = true
 	return d
@@ -162,11 +237,22 @@ func (d *DataFlow) SetBody(body io.Reader) *DataFlow {
 	return d
 }
 
+// SetFormWithFile 添加磁盘文件到 multipart 表单，可多次调用添加多个文件
 func (d *DataFlow) SetFormWithFile(filedName, fileName string) *DataFlow {
+	if form, ok := d.bodyEncoder.(*encode.FormEncode); ok {
+		form.FileFromPath(filedName, fileName)
+		return d
+	}
 	d.bodyEncoder = encode.NewFormEncoderWithFile(filedName, fileName)
 	return d
 }
 
+// SetMultipart 使用 multipart 编码器发送请求体，内容边编码边发送，Content-Type 带有对应的 boundary
+func (d *DataFlow) SetMultipart(form *encode.Multipart) *DataFlow {
+	d.bodyEncoder = form
+	return d
+}
+
 func (d *DataFlow) SetForm(data map[string]string) *DataFlow {
 	if d.bodyEncoder == nil {
 		d.bodyEncoder = encode.NewFormEncoderWithFiled(data)
@@ -190,23 +276,37 @@ func (d *DataFlow) SetJSON(data interface{}) *DataFlow {
 	return d
 }
 
-func (d *DataFlow) BindJSON(res interface{}) *DataFlow {
-	d.bodyDecoder = decode.NewJSONDecode(res)
-	return d
+// BindJSON 将响应按 JSON 解码到 res，传入 errRes 时非 2xx 响应解码到 errRes[0]
+func (d *DataFlow) BindJSON(res interface{}, errRes ...interface{}) *DataFlow {
+	return d.bind(decode.NewJSONDecode(res), decode.NewJSONDecode(first(errRes)))
 }
 
-func (d *DataFlow) BindXML(res interface{}) *DataFlow {
-	d.bodyDecoder = decode.NewXMLDecode(res)
-	return d
+// BindXML 将响应按 XML 解码到 res，传入 errRes 时非 2xx 响应解码到 errRes[0]
+func (d *DataFlow) BindXML(res interface{}, errRes ...interface{}) *DataFlow {
+	return d.bind(decode.NewXMLDecode(res), decode.NewXMLDecode(first(errRes)))
 }
 
-func (d *DataFlow) BindYAML(res interface{}) *DataFlow {
-	d.bodyDecoder = decode.NewYAMLDecode(res)
+// BindYAML 将响应按 YAML 解码到 res，传入 errRes 时非 2xx 响应解码到 errRes[0]
+func (d *DataFlow) BindYAML(res interface{}, errRes ...interface{}) *DataFlow {
+	return d.bind(decode.NewYAMLDecode(res), decode.NewYAMLDecode(first(errRes)))
+}
+
+func (d *DataFlow) bind(decoder, errDecoder decode.Decoder) *DataFlow {
+	d.bodyDecoder = decoder
+	d.errDecoder = errDecoder
 	return d
 }
 
+func first(values []interface{}) interface{} {
+	if len(values) == 0 {
+		return nil
+	}
+	return values[0]
+}
+
+// SetTimeout 设置本次请求的超时时间，通过请求 ctx 生效，不会修改底层 http.Client
 func (d *DataFlow) SetTimeout(timeout time.Duration) *DataFlow {
-	d.Timeout = timeout
+	d.timeout = timeout
 	return d
 }
 
@@ -215,39 +315,95 @@ func (d *DataFlow) WithContext(ctx context.Context) *DataFlow {
 	return d
 }
 
+// buildRequest 构造一次请求，每次重试都会重新编码 body，SetRequest 传入的请求会被复制而不是修改
 func (d *DataFlow) buildRequest() (*http.Request, error) {
 	var (
 		err error
 		req *http.Request
 	)
-	body := &bytes.Buffer{}
 	if d.req == nil {
-		if d.bodyEncoder != nil {
-			if err = d.bodyEncoder.Encode(body); d.Err != nil {
+		var body io.Reader
+		switch {
+		case d.stream != nil:
+			if seeker, ok := d.stream.(io.Seeker); ok {
+				if _, err = seeker.Seek(d.streamStart, io.SeekStart); err != nil {
+					return nil, err
+				}
+			}
+			body = d.stream
+		case d.bodyEncoder != nil:
+			if encoder, ok := d.bodyEncoder.(encode.StreamEncoder); ok {
+				return d.buildStreamRequest(encoder)
+			}
+			buffer := &bytes.Buffer{}
+			if err = d.bodyEncoder.Encode(buffer); err != nil {
 				return nil, err
 			}
+			body = buffer
 		}
-		req, err = http.NewRequest(d.method, d.url, body)
+		req, err = http.NewRequest(d.method, d.resolveURL(d.url), body)
 		if err != nil {
 			return nil, err
 		}
+		if d.stream != nil && d.streamSize >= 0 {
+			req.ContentLength = d.streamSize
+		}
 	} else {
-		req = d.req
+		req = d.req.Clone(d.req.Context())
+		if d.req.GetBody != nil {
+			if req.Body, err = d.req.GetBody(); err != nil {
+				return nil, err
+			}
+		}
 		if len(d.method) > 0 {
 			req.Method = d.method
 		}
 		if len(d.url) > 0 {
-			req.URL, err = url.Parse(d.url)
+			req.URL, err = url.Parse(d.resolveURL(d.url))
 			if err != nil {
 				return nil, err
 			}
 		}
 	}
+	return d.finishRequest(req)
+}
+
+// buildStreamRequest 通过 io.Pipe 边编码边发送请求体，使用 chunked 传输
+func (d *DataFlow) buildStreamRequest(encoder encode.StreamEncoder) (*http.Request, error) {
+	reader, writer := io.Pipe()
+	req, err := http.NewRequest(d.method, d.resolveURL(d.url), reader)
+	if err != nil {
+		reader.Close()
+		return nil, err
+	}
+	ctx := d.c
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	// 请求结束或失败时 transport 会关闭 body，编码随之以 io.ErrClosedPipe 结束
+	go func() {
+		if err := encoder.EncodeContext(ctx, writer); err != nil {
+			writer.CloseWithError(&requestError{err: err})
+			return
+		}
+		writer.Close()
+	}()
+	if req, err = d.finishRequest(req); err != nil {
+		reader.Close()
+		return nil, err
+	}
+	return req, nil
+}
+
+// finishRequest 设置 query、cookie、认证等与 body 无关的部分
+func (d *DataFlow) finishRequest(req *http.Request) (*http.Request, error) {
+	var err error
 	if d.queryEncoder != nil {
-		if err = d.queryEncoder.Encode(body); err != nil {
+		query := &bytes.Buffer{}
+		if err = d.queryEncoder.Encode(query); err != nil {
 			return nil, err
 		}
-		req.URL.RawQuery = body.String()
+		req.URL.RawQuery = query.String()
 	}
 
 	if d.c != nil {
@@ -262,9 +418,8 @@ func (d *DataFlow) buildRequest() (*http.Request, error) {
 		req.SetBasicAuth(*d.userName, *d.password)
 	}
 
-	if d.debug {
-		log.Printf("gout::request:%s %+v\n", d.url, body.String())
-		// fmt.Fprintf(os.Stdout, "gout::request:%s %+v\n", d.url, body.String())
+	if d.uploadProgress != nil && req.Body != nil {
+		req.Body = newProgressReader(req.Body, req.ContentLength, d.uploadProgress)
 	}
 
 	return req, nil
@@ -272,108 +427,238 @@ func (d *DataFlow) buildRequest() (*http.Request, error) {
 
 func (d *DataFlow) buildHeader() (http.Header, error) {
 	header := http.Header{}
+	if d.owner != nil {
+		for k, v := range d.owner.header {
+			header.Set(k, v)
+		}
+	}
 	for k, v := range d.headerEncoder {
 		header.Set(k, v)
 	}
-	if d.bodyEncoder != nil {
-		switch d.bodyEncoder.Name() {
-		case "json":
-			header.Set("Content-Type", "application/json")
-		case "form":
-			header.Set("Content-Type", "multipart/form-data")
-		case "www-form":
-			header.Set("Content-Type", "application/x-www-form-urlencoded")
-		}
+	if encoder, ok := d.bodyEncoder.(encode.ContentTyper); ok {
+		header.Set("Content-Type", encoder.ContentType())
+	}
+	if _, ok := d.bodyDecoder.(*codecDecoder); ok && header.Get("Accept") == "" {
+		header.Set("Accept", acceptHeader())
 	}
 	return header, nil
 }
 
-var pool = sync.Pool{
-	New: func() interface{} {
-		return bytes.NewBuffer(make([]byte, 4096))
-	},
-}
+// decodeBody 直接从响应 body 流式解码，解码后丢弃剩余内容以便连接复用。
+// 非 2xx 响应使用 errDecoder，其 body 为空时不报错。
+func (d *DataFlow) decodeBody(resp *http.Response, body io.Reader) error {
+	decoder := d.bodyDecoder
+	isErr := d.errDecoder != nil && (resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices)
+	if isErr {
+		decoder = d.errDecoder
+	}
+	if decoder == nil {
+		return nil
+	}
 
-func (d *DataFlow) decodeBody(reader io.Reader) ([]byte, error) {
 	var err error
-
-	if d.bodyDecoder == nil {
-		return nil, nil
+	if cd, ok := decoder.(*codecDecoder); ok {
+		err = cd.decodeAs(body, resp.Header.Get("Content-Type"))
+	} else {
+		err = decoder.Decode(body)
+	}
+	if err != nil && !(isErr && err == io.EOF) {
+		return err
 	}
-	// if len(bodyBytes) > 0 {
-	// 	d.resp.Body = ioutil.NopCloser(bytes.NewReader(bodyBytes))
-	// }
-	buffer := pool.Get().(*bytes.Buffer)
-	buffer.Reset()
-	defer pool.Put(buffer)
+	_, err = io.Copy(io.Discard, body)
+	return err
+}
 
-	if _, err = io.Copy(buffer, reader); err != nil {
-		return nil, err
+// Do 发送请求并返回状态码，状态码不符合 ExpectStatus 时同时返回状态码与 *StatusError
+func (d *DataFlow) Do() (int, error) {
+	resp, err := d.do(false)
+	if err != nil {
+		var statusErr *StatusError
+		if errors.As(err, &statusErr) {
+			return statusErr.StatusCode, err
+		}
+		return 0, err
 	}
+	return resp.StatusCode, nil
+}
 
-	if d.debug {
-		if buffer.Len() > 1024 {
-			log.Printf("gout::response:%s %+v\n", d.url, buffer.String()[:1024])
-		} else {
-			log.Printf("gout::response:%s %+v\n", d.url, buffer.String())
+// roundTrip 按重试策略发送请求并返回请求次数，返回的 done 需在读取完响应 body 后调用
+func (d *DataFlow) roundTrip() (*http.Response, context.CancelFunc, int, error) {
+	policy := d.retry
+	if policy == nil && d.owner != nil {
+		policy = d.owner.retry
+	}
+	if policy == nil {
+		policy = &RetryPolicy{MaxAttempts: 1}
+	}
+	if d.stream != nil {
+		// 流式请求体不能并发发送，无法 Seek 时也不能重发
+		p := *policy
+		p.HedgeDelay = 0
+		if _, ok := d.stream.(io.Seeker); !ok {
+			p.MaxAttempts = 1
 		}
-		// fmt.Fprintf(os.Stdout, "gout::response:%s %+v\n", d.url, buffer.String())
+		policy = &p
+	}
+	if d.req != nil && d.req.Body != nil && d.req.Body != http.NoBody && d.req.GetBody == nil {
+		// SetRequest 传入的 body 无法重新获取，只能发送一次
+		p := *policy
+		p.HedgeDelay = 0
+		p.MaxAttempts = 1
+		policy = &p
 	}
 
-	if err = d.bodyDecoder.Decode(buffer); err != nil {
-		return nil, err
+	parent := d.c
+	if parent == nil && d.req != nil {
+		parent = d.req.Context()
+	}
+	if parent == nil {
+		parent = context.Background()
 	}
 
-	return buffer.Bytes(), nil
-}
+	var (
+		resp       *http.Response
+		done       context.CancelFunc
+		err        error
+		attempts   int
+		idempotent = d.idempotent()
+	)
+	for attempt := 1; ; attempt++ {
+		attempts = attempt
+		if extra := policy.hedged(d.method); extra > 0 {
+			resp, done, err = d.hedge(parent, policy, attempt, extra)
+		} else {
+			resp, done, err = d.send(parent, attempt-1)
+		}
 
-func (d *DataFlow) Do() (int, error) {
-	if d.Err != nil {
-		return 0, d.Err
+		var retry bool
+		if err != nil {
+			retry = parent.Err() == nil && policy.retryError(err, idempotent)
+		} else {
+			retry = policy.retryStatus(resp.StatusCode)
+		}
+		if !retry || attempt >= policy.MaxAttempts {
+			break
+		}
+		delay, ok := policy.wait(attempt, resp)
+		if !ok {
+			break
+		}
+		discard(resp, done)
+		if err := sleep(parent, delay); err != nil {
+			return nil, nil, attempt, err
+		}
+	}
+	if err != nil {
+		return nil, nil, attempts, err
 	}
 
-	defer d.Reset()
-
-	d.req, d.Err = d.buildRequest()
-	if d.Err != nil {
-		return 0, d.Err
+	if d.downloadProgress != nil {
+		resp.Body = newProgressReader(resp.Body, resp.ContentLength, d.downloadProgress)
 	}
+	return resp, done, attempts, nil
+}
 
-	d.req.Header, d.Err = d.buildHeader()
-	if d.Err != nil {
-		return 0, d.Err
+// send 发送一次请求，resend 为重发次数；请求成功时调用方读取完响应后需调用返回的 done 释放超时 ctx
+func (d *DataFlow) send(parent context.Context, resend int) (*http.Response, context.CancelFunc, error) {
+	req, err := d.buildRequest()
+	if err != nil {
+		return nil, nil, &requestError{err: err}
 	}
 
-	if d.Timeout == 0 {
-		d.Timeout = defaultTimeout
+	req.Header, err = d.buildHeader()
+	if err != nil {
+		closeBody(req)
+		return nil, nil, &requestError{err: err}
 	}
 
-	var ctx context.Context
-	if d.c != nil {
-		ctx = d.c
+	// 超时时间与 WithContext 传入的 deadline 取较早者，请求在 ctx 取消时立即结束；每次重试单独计时
+	var (
+		ctx    context.Context
+		cancel context.CancelFunc
+	)
+	if timeout := d.requestTimeout(); timeout > 0 {
+		ctx, cancel = context.WithTimeout(parent, timeout)
 	} else {
-		ctx = context.Background()
+		ctx, cancel = context.WithCancel(parent)
+	}
+	req = req.WithContext(ctx)
+
+	req, span := startSpan(req, resend)
+	defer span.End()
+
+	resp, err := d.doer().Do(req)
+	if err != nil {
+		// 拦截器提前返回时 body 可能未被关闭，流式 body 的编码协程需要由此结束
+		closeBody(req)
+		cancel()
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, nil, err
+	}
+	span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+	if resp.StatusCode >= http.StatusBadRequest {
+		span.SetStatus(codes.Error, http.StatusText(resp.StatusCode))
+	}
+	return resp, cancel, nil
+}
+
+func closeBody(req *http.Request) {
+	if req.Body != nil {
+		req.Body.Close()
 	}
-	d.c, d.cancel = context.WithTimeout(ctx, d.Timeout)
-	defer d.cancel()
+}
 
-	d.resp, d.Err = d.Client.Do(d.req)
-	if d.Err != nil {
-		return 0, d.Err
+// requestTimeout 本次请求的超时时间，流式读取且未显式设置时返回 0
+func (d *DataFlow) requestTimeout() time.Duration {
+	if d.timeout > 0 {
+		return d.timeout
+	}
+	if d.owner != nil && d.owner.timeout > 0 {
+		return d.owner.timeout
 	}
+	if d.Client != nil && d.Client.Timeout > 0 {
+		return d.Client.Timeout
+	}
+	if d.streaming {
+		return 0
+	}
+	return defaultTimeout
+}
 
-	defer func() {
-		d.resp.Body.Close()
-		d.resp.Close = true
-		d.req.Close = true
-	}()
-	if _, d.Err = d.decodeBody(d.resp.Body); d.Err != nil {
-		return 0, d.Err
+// resolveURL 将相对地址拼接到 Client 的 base URL 之后
+func (d *DataFlow) resolveURL(rawURL string) string {
+	if d.owner == nil || d.owner.baseURL == "" || strings.Contains(rawURL, "://") {
+		return rawURL
+	}
+	if rawURL == "" {
+		return d.owner.baseURL
 	}
+	return strings.TrimRight(d.owner.baseURL, "/") + "/" + strings.TrimLeft(rawURL, "/")
+}
 
-	return d.resp.StatusCode, nil
+// startSpan 创建客户端 span 并将 traceparent 注入请求头，未配置 TracerProvider 时为 no-op
+func startSpan(req *http.Request, resend int) (*http.Request, trace.Span) {
+	attrs := []attribute.KeyValue{
+		attribute.String("http.request.method", req.Method),
+		attribute.String("url.full", redactURL(req.URL)),
+		attribute.String("server.address", req.URL.Host),
+	}
+	if resend > 0 {
+		attrs = append(attrs, attribute.Int("http.request.resend_count", resend))
+	}
+	ctx, span := otel.Tracer(tracerName).Start(req.Context(), "HTTP "+req.Method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrs...),
+	)
+	propagator.Inject(ctx, propagation.HeaderCarrier(req.Header))
+	return req.WithContext(ctx), span
 }
 
+// Cookie 返回最近一次请求响应中的 cookie
 func (d *DataFlow) Cookie() []*http.Cookie {
-	return d.resp.Cookies()
+	if d.last == nil {
+		return nil
+	}
+	return d.last.Cookies
 }
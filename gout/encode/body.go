@@ -1,12 +1,19 @@
 package encode
 
 import (
+	"bytes"
 	"errors"
 	"io"
+	"sync"
 )
 
 type BodyEncode struct {
+	mu     sync.Mutex
 	reader io.Reader
+	// data 缓存不可 Seek 的 reader 内容，重试时重复发送
+	data  []byte
+	start int64
+	read  bool
 }
 
 func NewBodyEncoder(obj io.Reader) Encoder {
@@ -17,8 +24,35 @@ func NewBodyEncoder(obj io.Reader) Encoder {
 	return &BodyEncode{reader: obj}
 }
 
+// Encode 可被多次调用（如请求重试），io.Seeker 会回到起始位置，其余 reader 首次读取后缓存
 func (j *BodyEncode) Encode(w io.Writer) error {
-	_, err := io.Copy(w, j.reader)
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if seeker, ok := j.reader.(io.Seeker); ok {
+		var err error
+		if j.read {
+			_, err = seeker.Seek(j.start, io.SeekStart)
+		} else {
+			j.start, err = seeker.Seek(0, io.SeekCurrent)
+		}
+		if err != nil {
+			return err
+		}
+		j.read = true
+		_, err = io.Copy(w, j.reader)
+		return err
+	}
+
+	if !j.read {
+		buf := &bytes.Buffer{}
+		if _, err := io.Copy(buf, j.reader); err != nil {
+			return err
+		}
+		j.data = buf.Bytes()
+		j.read = true
+	}
+	_, err := w.Write(j.data)
 	return err
 }
 
@@ -8,3 +8,8 @@ type Encoder interface {
 	Add(interface{}) error
 	Name() string
 }
+
+// ContentTyper 由编码器决定请求的 Content-Type
+type ContentTyper interface {
+	ContentType() string
+}
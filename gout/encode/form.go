@@ -1,55 +1,18 @@
 package encode
 
-import (
-	"errors"
-	"io"
-	"mime/multipart"
-)
-
+// FormEncode multipart/form-data 表单编码器
 type FormEncode struct {
-	obj       map[string]string
-	fileField string
-	filename  string
+	*Multipart
 }
 
 func NewFormEncoderWithFiled(data map[string]string) *FormEncode {
-	return &FormEncode{obj: data}
+	form := &FormEncode{Multipart: NewMultipart()}
+	form.Add(data)
+	return form
 }
 
 func NewFormEncoderWithFile(fieldname string, filename string) *FormEncode {
-	return &FormEncode{
-		obj:       map[string]string{},
-		filename:  filename,
-		fileField: fieldname,
-	}
-}
-
-func (j *FormEncode) Encode(w io.Writer) error {
-	p := multipart.NewWriter(w)
-	for k, v := range j.obj {
-		p.WriteField(k, v)
-	}
-
-	if j.filename != "" {
-		_, err := p.CreateFormFile(j.fileField, j.filename)
-		if err != nil {
-			return err
-		}
-	}
-
-	p.Close()
-	return nil
-}
-
-func (j *FormEncode) Add(data interface{}) error {
-	switch d := data.(type) {
-	case map[string]string:
-		for k, v := range d {
-			j.obj[k] = v
-		}
-		return nil
-	}
-	return errors.New("Not Support Form data type")
+	return &FormEncode{Multipart: NewMultipart().FileFromPath(fieldname, filename)}
 }
 
 func (j *FormEncode) Name() string {
@@ -22,7 +22,7 @@ func NewJSONEncoder(obj interface{}) Encoder {
 
 func (j *JSONEncode) Encode(w io.Writer) error {
 	if v, ok := conversion.GetBytes(j.obj); ok {
-		if ok = json.Valid(v); ok {
+		if ok = json.Valid(v); !ok {
 			return errors.New("Not json data")
 		}
 		_, err := w.Write(v)
@@ -44,3 +44,7 @@ func (j *JSONEncode) Add(interface{}) error {
 func (j *JSONEncode) Name() string {
 	return "json"
 }
+
+func (j *JSONEncode) ContentType() string {
+	return "application/json"
+}
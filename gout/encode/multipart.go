@@ -0,0 +1,208 @@
+package encode
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// StreamEncoder 边编码边发送的编码器，Content-Type 由编码器提供（如带 boundary 的 multipart）
+type StreamEncoder interface {
+	Encoder
+	ContentTyper
+	EncodeContext(ctx context.Context, w io.Writer) error
+}
+
+// Downloader 文件下载接口，storage.Storage 满足该接口
+type Downloader interface {
+	Download(ctx context.Context, path string) (io.ReadCloser, error)
+}
+
+// PartOption 文件字段配置
+type PartOption func(*filePart)
+
+// WithFileName 设置文件名，默认使用路径中的文件名
+func WithFileName(name string) PartOption {
+	return func(p *filePart) {
+		p.filename = name
+	}
+}
+
+// WithContentType 设置文件的 Content-Type，默认按扩展名推断，推断失败时为 application/octet-stream
+func WithContentType(contentType string) PartOption {
+	return func(p *filePart) {
+		p.contentType = contentType
+	}
+}
+
+type filePart struct {
+	field       string
+	filename    string
+	contentType string
+	open        func(ctx context.Context) (io.ReadCloser, error)
+}
+
+type multipartPart struct {
+	// field 为文件字段时 file 不为 nil
+	field string
+	value string
+	file  *filePart
+}
+
+// Multipart multipart/form-data 编码器，字段与文件按添加顺序流式写出，文件内容在发送时才读取
+type Multipart struct {
+	boundary string
+	parts    []multipartPart
+}
+
+// NewMultipart 创建 multipart 编码器并生成随机 boundary
+func NewMultipart() *Multipart {
+	return &Multipart{boundary: multipart.NewWriter(io.Discard).Boundary()}
+}
+
+// Field 添加普通字段
+func (m *Multipart) Field(name, value string) *Multipart {
+	m.parts = append(m.parts, multipartPart{field: name, value: value})
+	return m
+}
+
+// FileFromPath 添加磁盘文件，每次发送时重新打开，可以重试
+func (m *Multipart) FileFromPath(field, path string, opts ...PartOption) *Multipart {
+	return m.addFile(field, filepath.Base(path), func(context.Context) (io.ReadCloser, error) {
+		return os.Open(path)
+	}, opts)
+}
+
+// FileFromReader 添加 reader 中的文件内容。reader 实现 io.Seeker 时重试会从起始位置重新读取，
+// 否则只能发送一次。
+func (m *Multipart) FileFromReader(field, filename string, reader io.Reader, opts ...PartOption) *Multipart {
+	var (
+		mu    sync.Mutex
+		start int64 = -1
+		used  bool
+	)
+	return m.addFile(field, filename, func(context.Context) (io.ReadCloser, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if seeker, ok := reader.(io.Seeker); ok {
+			var err error
+			if start < 0 {
+				start, err = seeker.Seek(0, io.SeekCurrent)
+			} else {
+				_, err = seeker.Seek(start, io.SeekStart)
+			}
+			if err != nil {
+				return nil, err
+			}
+			return io.NopCloser(reader), nil
+		}
+		if used {
+			return nil, fmt.Errorf("multipart: file %q reader has already been consumed", filename)
+		}
+		used = true
+		return io.NopCloser(reader), nil
+	}, opts)
+}
+
+// FileFromStorage 添加从存储下载的文件，每次发送时重新下载
+func (m *Multipart) FileFromStorage(field string, storage Downloader, path string, opts ...PartOption) *Multipart {
+	return m.addFile(field, filepath.Base(path), func(ctx context.Context) (io.ReadCloser, error) {
+		return storage.Download(ctx, path)
+	}, opts)
+}
+
+func (m *Multipart) addFile(field, filename string, open func(ctx context.Context) (io.ReadCloser, error), opts []PartOption) *Multipart {
+	part := &filePart{field: field, filename: filename, open: open}
+	for _, opt := range opts {
+		opt(part)
+	}
+	if part.contentType == "" {
+		part.contentType = mime.TypeByExtension(filepath.Ext(part.filename))
+	}
+	if part.contentType == "" {
+		part.contentType = "application/octet-stream"
+	}
+	m.parts = append(m.parts, multipartPart{field: field, file: part})
+	return m
+}
+
+// ContentType 带 boundary 的 Content-Type
+func (m *Multipart) ContentType() string {
+	return "multipart/form-data; boundary=" + m.boundary
+}
+
+func (m *Multipart) Encode(w io.Writer) error {
+	return m.EncodeContext(context.Background(), w)
+}
+
+// EncodeContext 写出全部字段，ctx 用于下载存储中的文件
+func (m *Multipart) EncodeContext(ctx context.Context, w io.Writer) error {
+	writer := multipart.NewWriter(w)
+	if err := writer.SetBoundary(m.boundary); err != nil {
+		return err
+	}
+
+	for _, part := range m.parts {
+		if part.file == nil {
+			if err := writer.WriteField(part.field, part.value); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := writeFile(ctx, writer, part.file); err != nil {
+			return err
+		}
+	}
+	return writer.Close()
+}
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+func writeFile(ctx context.Context, writer *multipart.Writer, file *filePart) error {
+	reader, err := file.open(ctx)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`,
+		quoteEscaper.Replace(file.field), quoteEscaper.Replace(file.filename)))
+	header.Set("Content-Type", file.contentType)
+	w, err := writer.CreatePart(header)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, reader)
+	return err
+}
+
+// Add 添加 map[string]string 类型的普通字段
+func (m *Multipart) Add(data interface{}) error {
+	switch d := data.(type) {
+	case map[string]string:
+		keys := make([]string, 0, len(d))
+		for k := range d {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			m.Field(k, d[k])
+		}
+		return nil
+	}
+	return errors.New("Not Support Form data type")
+}
+
+func (m *Multipart) Name() string {
+	return "multipart"
+}
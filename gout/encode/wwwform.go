@@ -37,3 +37,7 @@ func (j *WWWFormEncode) Add(data interface{}) error {
 func (j *WWWFormEncode) Name() string {
 	return "www-form"
 }
+
+func (j *WWWFormEncode) ContentType() string {
+	return "application/x-www-form-urlencoded"
+}
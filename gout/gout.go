@@ -19,3 +19,23 @@ func PUT(url string) *DataFlow {
 func DELETE(url string) *DataFlow {
 	return New().DELETE(url)
 }
+
+// PATCH send HTTP PATCH method
+func PATCH(url string) *DataFlow {
+	return New().PATCH(url)
+}
+
+// HEAD send HTTP HEAD method
+func HEAD(url string) *DataFlow {
+	return New().HEAD(url)
+}
+
+// OPTIONS send HTTP OPTIONS method
+func OPTIONS(url string) *DataFlow {
+	return New().OPTIONS(url)
+}
+
+// Method send HTTP request with any method
+func Method(method, url string) *DataFlow {
+	return New().Method(method, url)
+}
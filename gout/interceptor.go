@@ -0,0 +1,212 @@
+package gout
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ffhuo/go-kits/common/field"
+)
+
+// Doer 执行 HTTP 请求，*http.Client 满足该接口
+type Doer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// DoerFunc 函数形式的 Doer
+type DoerFunc func(req *http.Request) (*http.Response, error)
+
+func (f DoerFunc) Do(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// Interceptor 客户端拦截器，可在调用 next 前修改请求，或包装 next 返回的响应。
+// 开启重试时每次尝试都会经过拦截器。
+type Interceptor func(req *http.Request, next Doer) (*http.Response, error)
+
+var (
+	interceptorMu      sync.RWMutex
+	globalInterceptors []Interceptor
+
+	debugLogger      Logger = stdLogger{}
+	debugMaxBodySize        = 4096
+)
+
+// Use 注册全局拦截器，对之后发出的所有请求生效，按注册顺序由外向内执行
+func Use(interceptors ...Interceptor) {
+	interceptorMu.Lock()
+	defer interceptorMu.Unlock()
+	globalInterceptors = append(globalInterceptors, interceptors...)
+}
+
+// Chain 将拦截器串联到 doer 之上，第一个拦截器最先执行
+func Chain(doer Doer, interceptors ...Interceptor) Doer {
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		interceptor, next := interceptors[i], doer
+		doer = DoerFunc(func(req *http.Request) (*http.Response, error) {
+			return interceptor(req, next)
+		})
+	}
+	return doer
+}
+
+// Transport 将拦截器包装为 http.RoundTripper，用于为单个 http.Client 注册拦截器，base 为 nil 时使用 http.DefaultTransport
+func Transport(base http.RoundTripper, interceptors ...Interceptor) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	doer := Chain(DoerFunc(base.RoundTrip), interceptors...)
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		// RoundTripper 不应修改传入的请求
+		return doer.Do(req.Clone(req.Context()))
+	})
+}
+
+type roundTripperFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// Use 为本次请求追加拦截器，在全局及 Client 的拦截器之内执行
+func (d *DataFlow) Use(interceptors ...Interceptor) *DataFlow {
+	d.interceptors = append(d.interceptors, interceptors...)
+	return d
+}
+
+// doer 组合全局、Client、本次请求的拦截器与 Debug 日志
+func (d *DataFlow) doer() Doer {
+	interceptorMu.RLock()
+	interceptors := make([]Interceptor, 0, len(globalInterceptors)+len(d.interceptors)+1)
+	interceptors = append(interceptors, globalInterceptors...)
+	logger, maxBodySize := debugLogger, debugMaxBodySize
+	interceptorMu.RUnlock()
+
+	if d.owner != nil {
+		interceptors = append(interceptors, d.owner.interceptors...)
+	}
+	interceptors = append(interceptors, d.interceptors...)
+	if d.debug {
+		// 流式响应不预读 body，避免阻塞
+		if d.streaming {
+			maxBodySize = 0
+		}
+		interceptors = append(interceptors, Logging(logger, maxBodySize))
+	}
+	return Chain(d.Client, interceptors...)
+}
+
+// Logger 日志接口，兼容 logger 和 logger_v2
+type Logger interface {
+	Info(ctx context.Context, msg string, data ...interface{})
+	Error(ctx context.Context, msg string, data ...interface{})
+}
+
+// stdLogger 使用标准库 log 输出
+type stdLogger struct{}
+
+func (stdLogger) Info(_ context.Context, msg string, data ...interface{}) {
+	log.Printf(msg, data...)
+}
+
+func (stdLogger) Error(_ context.Context, msg string, data ...interface{}) {
+	log.Printf(msg, data...)
+}
+
+// SetDebugLogger 设置 Debug() 使用的日志实例及记录的最大 body 长度，默认使用标准库 log 且最多记录 4KB
+func SetDebugLogger(logger Logger, maxBodySize int) {
+	interceptorMu.Lock()
+	defer interceptorMu.Unlock()
+	debugLogger = logger
+	debugMaxBodySize = maxBodySize
+}
+
+// Logging 记录请求方法、地址、状态码、耗时及 body，body 最多记录 maxBodySize 字节，为 0 时不记录 body。
+// 读取响应 body 时会等待至多 maxBodySize 字节，流式响应应将其设为 0。
+func Logging(logger Logger, maxBodySize int) Interceptor {
+	return func(req *http.Request, next Doer) (*http.Response, error) {
+		ctx := req.Context()
+		var reqBody []byte
+		if maxBodySize > 0 && req.GetBody != nil {
+			if body, err := req.GetBody(); err == nil {
+				reqBody, _ = io.ReadAll(io.LimitReader(body, int64(maxBodySize)))
+				body.Close()
+			}
+		}
+
+		start := time.Now()
+		resp, err := next.Do(req)
+		cost := time.Since(start)
+		if err != nil {
+			logger.Error(ctx, "gout: %s %s error: %v, cost: %s, request: %s", req.Method, req.URL, err, cost, reqBody)
+			return resp, err
+		}
+
+		var respBody []byte
+		if maxBodySize > 0 {
+			respBody, err = io.ReadAll(io.LimitReader(resp.Body, int64(maxBodySize)))
+			// 已读取的部分放回 body，调用方仍能读取完整响应
+			resp.Body = struct {
+				io.Reader
+				io.Closer
+			}{io.MultiReader(bytes.NewReader(respBody), resp.Body), resp.Body}
+			if err != nil {
+				logger.Error(ctx, "gout: %s %s read response error: %v", req.Method, req.URL, err)
+			}
+		}
+		logger.Info(ctx, "gout: %s %s %d, cost: %s, request: %s, response: %s", req.Method, req.URL, resp.StatusCode, cost, reqBody, respBody)
+		return resp, nil
+	}
+}
+
+// BearerToken 每次请求前通过 token 获取令牌并设置 Authorization 头
+func BearerToken(token func(ctx context.Context) (string, error)) Interceptor {
+	return func(req *http.Request, next Doer) (*http.Response, error) {
+		t, err := token(req.Context())
+		if err != nil {
+			return nil, fmt.Errorf("gout: get token: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+t)
+		return next.Do(req)
+	}
+}
+
+// Sign 在请求发出前调用 signer 签名，signer 可通过 req.GetBody 读取 body 而不消耗它
+func Sign(signer func(req *http.Request) error) Interceptor {
+	return func(req *http.Request, next Doer) (*http.Response, error) {
+		if err := signer(req); err != nil {
+			return nil, fmt.Errorf("gout: sign request: %w", err)
+		}
+		return next.Do(req)
+	}
+}
+
+// DefaultPropagateFields 默认透传的字段，请求 ID 写入 X-Request-ID
+var DefaultPropagateFields = map[string]string{
+	"request_id": "X-Request-ID",
+}
+
+// PropagateFields 将 ctx 中 common/field 记录的字段写入请求头，fields 为字段名到请求头的映射，
+// 为 nil 时使用 DefaultPropagateFields。已存在的请求头不会被覆盖。
+func PropagateFields(fields map[string]string) Interceptor {
+	if fields == nil {
+		fields = DefaultPropagateFields
+	}
+	return func(req *http.Request, next Doer) (*http.Response, error) {
+		for _, meta := range field.Get(req.Context()) {
+			header, ok := fields[meta.Key()]
+			if !ok || req.Header.Get(header) != "" {
+				continue
+			}
+			if v := fmt.Sprint(meta.Value()); v != "" {
+				req.Header.Set(header, v)
+			}
+		}
+		return next.Do(req)
+	}
+}
@@ -0,0 +1,192 @@
+package gout
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ffhuo/go-kits/common/field"
+)
+
+type memLogger struct {
+	infos  []string
+	errors []string
+}
+
+func (l *memLogger) Info(_ context.Context, msg string, data ...interface{}) {
+	l.infos = append(l.infos, fmt.Sprintf(msg, data...))
+}
+
+func (l *memLogger) Error(_ context.Context, msg string, data ...interface{}) {
+	l.errors = append(l.errors, fmt.Sprintf(msg, data...))
+}
+
+// record 记录拦截器执行顺序
+func record(order *[]string, name string) Interceptor {
+	return func(req *http.Request, next Doer) (*http.Response, error) {
+		*order = append(*order, name+">")
+		resp, err := next.Do(req)
+		*order = append(*order, "<"+name)
+		return resp, err
+	}
+}
+
+func TestInterceptorOrder(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.Header.Get("X-Trace"))
+	}))
+	defer srv.Close()
+
+	var order []string
+	Use(record(&order, "global"))
+	defer func() {
+		interceptorMu.Lock()
+		globalInterceptors = nil
+		interceptorMu.Unlock()
+	}()
+
+	client := NewClient(WithInterceptors(record(&order, "client1"), record(&order, "client2")))
+	resp, err := client.GET(srv.URL).Use(record(&order, "request"), func(req *http.Request, next Doer) (*http.Response, error) {
+		req.Header.Set("X-Trace", "set by interceptor")
+		return next.Do(req)
+	}).DoResponse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(resp.Body) != "set by interceptor" {
+		t.Fatalf("expected header set by interceptor, got %q", resp.Body)
+	}
+	want := "global> client1> client2> request> <request <client2 <client1 <global"
+	if got := strings.Join(order, " "); got != want {
+		t.Fatalf("expected %q, got %q", want, got)
+	}
+
+	// 拦截器可直接返回响应，不发出请求
+	order = nil
+	code, err := New().GET(srv.URL).Use(func(req *http.Request, next Doer) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusTeapot, Body: http.NoBody, Request: req}, nil
+	}).Do()
+	if err != nil || code != http.StatusTeapot {
+		t.Fatalf("expected short-circuit response, got %d %v", code, err)
+	}
+}
+
+func TestTransportInterceptors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.Header.Get("Authorization"))
+	}))
+	defer srv.Close()
+
+	var order []string
+	client := &http.Client{Transport: Transport(nil, record(&order, "a"), record(&order, "b"),
+		BearerToken(func(ctx context.Context) (string, error) { return "t0k3n", nil }))}
+	req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if string(body) != "Bearer t0k3n" || strings.Join(order, " ") != "a> b> <b <a" {
+		t.Fatalf("unexpected body %q or order %v", body, order)
+	}
+	// RoundTripper 不修改调用方的请求
+	if req.Header.Get("Authorization") != "" {
+		t.Fatal("expected original request to be untouched")
+	}
+}
+
+func TestLogging(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Write(append([]byte("echo:"), body...))
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name        string
+		maxBodySize int
+		request     string
+		response    string
+	}{
+		{"full body", 1024, "request: hello", "response: echo:hello"},
+		{"truncated", 4, "request: hell,", "response: echo"},
+		{"no body", 0, "request: ,", "response: "},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger := &memLogger{}
+			resp, err := New().POST(srv.URL).SetBody(strings.NewReader("hello")).
+				Use(Logging(logger, tt.maxBodySize)).DoResponse()
+			if err != nil {
+				t.Fatal(err)
+			}
+			// 记录日志后请求与响应 body 仍完整
+			if string(resp.Body) != "echo:hello" {
+				t.Fatalf("expected full response body, got %q", resp.Body)
+			}
+			if len(logger.infos) != 1 || !strings.Contains(logger.infos[0], tt.request) || !strings.HasSuffix(logger.infos[0], tt.response) {
+				t.Fatalf("unexpected log %v", logger.infos)
+			}
+		})
+	}
+
+	logger := &memLogger{}
+	_, err := New().GET(srv.URL).Use(Logging(logger, 10), func(req *http.Request, next Doer) (*http.Response, error) {
+		return nil, errors.New("boom")
+	}).Do()
+	if err == nil || len(logger.errors) != 1 || !strings.Contains(logger.errors[0], "boom") {
+		t.Fatalf("expected error to be logged, got %v %v", err, logger.errors)
+	}
+}
+
+func TestSignAndPropagate(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%s|%s|%s", r.Header.Get("X-Signature"), r.Header.Get("X-Request-ID"), r.Header.Get("X-Tenant"))
+	}))
+	defer srv.Close()
+
+	sign := Sign(func(req *http.Request) error {
+		body, err := req.GetBody()
+		if err != nil {
+			return err
+		}
+		data, _ := io.ReadAll(body)
+		req.Header.Set("X-Signature", fmt.Sprintf("%x", len(data)))
+		return nil
+	})
+	ctx := field.With(context.Background(), field.F("request_id", "req-1"), field.F("tenant", "acme"))
+
+	tests := []struct {
+		name   string
+		fields map[string]string
+		header map[string]string
+		want   string
+	}{
+		{"default fields", nil, nil, "5|req-1|"},
+		{"custom fields", map[string]string{"tenant": "X-Tenant"}, nil, "5||acme"},
+		{"existing header", nil, map[string]string{"X-Request-ID": "mine"}, "5|mine|"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := New().POST(srv.URL).WithContext(ctx).SetHeader(tt.header).
+				SetBody(strings.NewReader("hello")).Use(sign, PropagateFields(tt.fields)).DoResponse()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(resp.Body) != tt.want {
+				t.Fatalf("expected %q, got %q", tt.want, resp.Body)
+			}
+		})
+	}
+
+	failing := Sign(func(req *http.Request) error { return errors.New("no key") })
+	if _, err := New().GET(srv.URL).Use(failing).Do(); err == nil || !strings.Contains(err.Error(), "sign request: no key") {
+		t.Fatalf("expected sign error, got %v", err)
+	}
+}
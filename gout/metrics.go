@@ -0,0 +1,50 @@
+package gout
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ffhuo/go-kits/common/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Metrics 记录客户端请求指标，指标注册到 registerer，默认 prometheus.DefaultRegisterer，重复创建时复用已注册的指标。
+// 耗时统计到收到响应头为止，请求失败时 code 为 error。
+func Metrics(registerer ...prometheus.Registerer) Interceptor {
+	reg := prometheus.DefaultRegisterer
+	if len(registerer) > 0 && registerer[0] != nil {
+		reg = registerer[0]
+	}
+
+	duration := metrics.MustRegister(reg, prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "http_client_request_duration_seconds",
+			Help:    "HTTP client request duration in seconds",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"method", "host", "code"},
+	))
+	inFlight := metrics.MustRegister(reg, prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "http_client_requests_in_flight",
+			Help: "Number of HTTP client requests in flight",
+		},
+		[]string{"host"},
+	))
+
+	return func(req *http.Request, next Doer) (*http.Response, error) {
+		gauge := inFlight.WithLabelValues(req.URL.Host)
+		gauge.Inc()
+		defer gauge.Dec()
+
+		start := time.Now()
+		resp, err := next.Do(req)
+		code := "error"
+		if err == nil {
+			code = strconv.Itoa(resp.StatusCode)
+		}
+		duration.WithLabelValues(req.Method, req.URL.Host, code).Observe(time.Since(start).Seconds())
+		return resp, err
+	}
+}
@@ -0,0 +1,231 @@
+package mock
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Mode 录制回放模式
+type Mode int
+
+const (
+	// ModeReplay 只从 cassette 回放，未录制的请求返回错误，cassette 不存在时 NewRecorder 返回错误
+	ModeReplay Mode = iota
+	// ModeRecord 请求真实服务并录制，Save 时覆盖 cassette
+	ModeRecord
+	// ModeAuto cassette 存在时回放，否则录制
+	ModeAuto
+)
+
+// redacted 脱敏后的占位值
+const redacted = "REDACTED"
+
+// multipartBoundary 录制及回放时替换 multipart 请求中随机生成的 boundary，使相同的上传内容可以匹配
+const multipartBoundary = "gout-mock-boundary"
+
+// Interaction cassette 中的一次请求与响应
+type Interaction struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+// RecordedRequest 录制的请求
+type RecordedRequest struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header,omitempty"`
+	Body   string      `json:"body,omitempty"`
+}
+
+// RecordedResponse 录制的响应
+type RecordedResponse struct {
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       string      `json:"body,omitempty"`
+}
+
+// Cassette 录制文件内容
+type Cassette struct {
+	Interactions []*Interaction `json:"interactions"`
+}
+
+// Recorder 录制/回放用的 http.RoundTripper。回放时按 method、URL 与 body 匹配，
+// 相同请求录制了多次时按顺序依次返回。
+type Recorder struct {
+	path string
+	mode Mode
+	real http.RoundTripper
+
+	// 脱敏的请求头、响应头与 query 参数，保存时替换为 REDACTED，回放时不参与匹配
+	redactHeaders []string
+	redactQuery   []string
+
+	mu       sync.Mutex
+	cassette *Cassette
+	used     []bool
+}
+
+// NewRecorder 创建 Recorder，real 为录制时使用的真实 Transport，为 nil 时使用 http.DefaultTransport。
+// 默认对 Authorization、Cookie 请求头及 Set-Cookie 响应头脱敏。
+func NewRecorder(path string, mode Mode, real http.RoundTripper) (*Recorder, error) {
+	if real == nil {
+		real = http.DefaultTransport
+	}
+	r := &Recorder{
+		path:          path,
+		mode:          mode,
+		real:          real,
+		redactHeaders: []string{"Authorization", "Cookie", "Set-Cookie"},
+		cassette:      &Cassette{},
+	}
+
+	data, err := os.ReadFile(path)
+	switch {
+	case err == nil && mode != ModeRecord:
+		if err = json.Unmarshal(data, r.cassette); err != nil {
+			return nil, fmt.Errorf("mock: load cassette %s: %w", path, err)
+		}
+		r.mode = ModeReplay
+		r.used = make([]bool, len(r.cassette.Interactions))
+	case errors.Is(err, os.ErrNotExist) && mode == ModeAuto:
+		r.mode = ModeRecord
+	case err != nil && mode != ModeRecord:
+		// 权限不足等读取失败时不能当作 cassette 不存在，否则 ModeAuto 会录制并覆盖原有文件
+		return nil, fmt.Errorf("mock: load cassette %s: %w", path, err)
+	}
+	return r, nil
+}
+
+// RedactHeaders 追加需要脱敏的请求头及响应头
+func (r *Recorder) RedactHeaders(keys ...string) *Recorder {
+	r.redactHeaders = append(r.redactHeaders, keys...)
+	return r
+}
+
+// RedactQuery 追加需要脱敏的 query 参数，如 access_token
+func (r *Recorder) RedactQuery(keys ...string) *Recorder {
+	r.redactQuery = append(r.redactQuery, keys...)
+	return r
+}
+
+// Recording 是否处于录制模式
+func (r *Recorder) Recording() bool {
+	return r.mode == ModeRecord
+}
+
+// Client 返回使用该 Recorder 的 http.Client
+func (r *Recorder) Client() *http.Client {
+	return &http.Client{Transport: r}
+}
+
+// RoundTrip 实现 http.RoundTripper
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		if body, err = io.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		req.Body.Close()
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	recorded := r.recordRequest(req, body)
+
+	if r.mode != ModeRecord {
+		return r.replay(req, recorded)
+	}
+
+	resp, err := r.real.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	r.mu.Lock()
+	r.cassette.Interactions = append(r.cassette.Interactions, &Interaction{
+		Request: recorded,
+		Response: RecordedResponse{
+			StatusCode: resp.StatusCode,
+			Header:     r.redactHeader(resp.Header),
+			Body:       string(respBody),
+		},
+	})
+	r.mu.Unlock()
+	return resp, nil
+}
+
+func (r *Recorder) replay(req *http.Request, recorded RecordedRequest) (*http.Response, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, it := range r.cassette.Interactions {
+		if r.used[i] || it.Request.Method != recorded.Method || it.Request.URL != recorded.URL || it.Request.Body != recorded.Body {
+			continue
+		}
+		r.used[i] = true
+		return NewResponse(req, it.Response.StatusCode, it.Response.Header, []byte(it.Response.Body)), nil
+	}
+	return nil, fmt.Errorf("mock: no recorded interaction for %s %s in %s", recorded.Method, recorded.URL, r.path)
+}
+
+func (r *Recorder) recordRequest(req *http.Request, body []byte) RecordedRequest {
+	u := *req.URL
+	if len(r.redactQuery) > 0 {
+		query := u.Query()
+		for _, key := range r.redactQuery {
+			if query.Has(key) {
+				query.Set(key, redacted)
+			}
+		}
+		u.RawQuery = query.Encode()
+	}
+
+	header := r.redactHeader(req.Header)
+	if mediaType, params, err := mime.ParseMediaType(header.Get("Content-Type")); err == nil &&
+		strings.HasPrefix(mediaType, "multipart/") && params["boundary"] != "" {
+		body = bytes.ReplaceAll(body, []byte(params["boundary"]), []byte(multipartBoundary))
+		params["boundary"] = multipartBoundary
+		header.Set("Content-Type", mime.FormatMediaType(mediaType, params))
+	}
+	return RecordedRequest{Method: req.Method, URL: u.String(), Header: header, Body: string(body)}
+}
+
+func (r *Recorder) redactHeader(h http.Header) http.Header {
+	header := h.Clone()
+	for _, key := range r.redactHeaders {
+		if header.Get(key) != "" {
+			header.Set(key, redacted)
+		}
+	}
+	return header
+}
+
+// Save 录制模式下将 cassette 写入磁盘，回放模式下不做任何操作
+func (r *Recorder) Save() error {
+	if r.mode != ModeRecord {
+		return nil
+	}
+	r.mu.Lock()
+	data, err := json.MarshalIndent(r.cassette, "", "  ")
+	r.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(r.path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(r.path, data, 0o644)
+}
@@ -0,0 +1,33 @@
+package mock_test
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/ffhuo/go-kits/gout"
+	"github.com/ffhuo/go-kits/gout/mock"
+)
+
+func ExampleTransport() {
+	m := mock.New()
+	m.On("GET", "https://api.example.com/users/*").ReplyJSON(http.StatusOK, map[string]string{"name": "alice"})
+
+	// 通过 client 注入 mock，不修改 http.DefaultClient
+	var user struct{ Name string }
+	code, err := gout.New(m.Client()).GET("https://api.example.com/users/1").BindJSON(&user).Do()
+	fmt.Println(code, user.Name, err)
+	// Output: 200 alice <nil>
+}
+
+func ExampleTransport_Install() {
+	m := mock.New()
+	m.On("", "/ping").ReplyString(http.StatusOK, "pong")
+
+	// 只在无法注入 client 时使用，调用方的测试不能使用 t.Parallel()
+	restore := m.Install()
+	defer restore()
+
+	resp, err := gout.GET("http://localhost/ping").DoResponse()
+	fmt.Println(resp.StatusCode, string(resp.Body), err)
+	// Output: 200 pong <nil>
+}
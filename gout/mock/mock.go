@@ -0,0 +1,453 @@
+// Package mock 提供用于测试 gout 调用方的 http.RoundTripper：按路由返回预设响应、断言调用，
+// 以及把真实请求录制到磁盘并在之后回放。
+//
+// 推荐通过 gout.New(m.Client()) 或 gout.WithTransport(m) 把 mock 注入被测代码，每个测试使用独立的 Transport，
+// 可以与 t.Parallel() 一起使用；Install 会修改全局的 http.DefaultClient，只在无法注入 client 时使用。
+package mock
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// TestingT *testing.T 满足该接口
+type TestingT interface {
+	Helper()
+	Errorf(format string, args ...interface{})
+}
+
+// Call 一次被记录的请求
+type Call struct {
+	Method string
+	URL    *url.URL
+	Header http.Header
+	Body   []byte
+	// Route 命中的路由，未命中时为 nil
+	Route *Route
+}
+
+// Transport 可编程的 http.RoundTripper，按注册顺序匹配路由并返回预设响应
+type Transport struct {
+	mu     sync.Mutex
+	routes []*Route
+	calls  []*Call
+
+	// Fallback 未命中任何路由时使用，为 nil 时返回错误
+	Fallback http.RoundTripper
+}
+
+// New 创建 Transport
+func New() *Transport {
+	return &Transport{}
+}
+
+// Client 返回使用该 Transport 的 http.Client，可传给 gout.New 或 gout.WithHTTPClient
+func (m *Transport) Client() *http.Client {
+	return &http.Client{Transport: m}
+}
+
+// Install 将 http.DefaultClient 的 Transport 替换为 m，使 gout.GET 等包级函数也经过 mock，返回的函数用于恢复。
+// 修改的是进程内的全局状态，调用 Install 的测试及同一包中其它使用 http.DefaultClient 的测试都不能使用 t.Parallel()，
+// 能注入 client 时应使用 gout.New(m.Client())
+func (m *Transport) Install() (restore func()) {
+	original := http.DefaultClient.Transport
+	http.DefaultClient.Transport = m
+	return func() {
+		http.DefaultClient.Transport = original
+	}
+}
+
+// On 注册路由。method 为空或 * 时匹配任意方法；rawURL 以 / 开头时只匹配路径，否则匹配 scheme、host 与路径，
+// 两者均支持 path.Match 通配符，rawURL 中的 query 参数要求请求中存在相同的值
+func (m *Transport) On(method, rawURL string) *Route {
+	r := &Route{owner: m, method: strings.ToUpper(method), status: http.StatusOK, header: http.Header{}, query: url.Values{}}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		r.err = fmt.Errorf("invalid url %q: %w", rawURL, err)
+	} else {
+		r.path = u.Path
+		if u.Host != "" {
+			r.host = u.Scheme + "://" + u.Host
+		}
+		r.query = u.Query()
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.routes = append(m.routes, r)
+	return r
+}
+
+// RoundTrip 实现 http.RoundTripper
+func (m *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		if body, err = io.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		req.Body.Close()
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	call := &Call{Method: req.Method, URL: req.URL, Header: req.Header.Clone(), Body: body}
+	var routeErr error
+	m.mu.Lock()
+	for _, r := range m.routes {
+		// 配置有误的路由无法判断是否匹配，直接返回错误而不是跳过
+		if r.err != nil {
+			routeErr = fmt.Errorf("mock: invalid route %s: %w", r, r.err)
+			break
+		}
+		if r.match(req, body) {
+			r.calls++
+			call.Route = r
+			break
+		}
+	}
+	m.calls = append(m.calls, call)
+	fallback := m.Fallback
+	m.mu.Unlock()
+
+	if routeErr != nil {
+		return nil, routeErr
+	}
+	if call.Route == nil {
+		if fallback != nil {
+			return fallback.RoundTrip(req)
+		}
+		return nil, fmt.Errorf("mock: no route for %s %s", req.Method, req.URL)
+	}
+	return call.Route.respond(req)
+}
+
+// Calls 返回全部请求记录
+func (m *Transport) Calls() []*Call {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]*Call(nil), m.calls...)
+}
+
+// Reset 清除路由与请求记录
+func (m *Transport) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.routes = nil
+	m.calls = nil
+}
+
+// AssertCalled 断言存在匹配 method 与 rawURL 的请求，rawURL 规则与 On 相同
+func (m *Transport) AssertCalled(t TestingT, method, rawURL string) bool {
+	t.Helper()
+	if m.countCalls(method, rawURL) == 0 {
+		t.Errorf("mock: expected %s %s to be called, got calls: %s", method, rawURL, m.describeCalls())
+		return false
+	}
+	return true
+}
+
+// AssertNotCalled 断言不存在匹配 method 与 rawURL 的请求
+func (m *Transport) AssertNotCalled(t TestingT, method, rawURL string) bool {
+	t.Helper()
+	if n := m.countCalls(method, rawURL); n > 0 {
+		t.Errorf("mock: expected %s %s not to be called, but it was called %d times", method, rawURL, n)
+		return false
+	}
+	return true
+}
+
+// AssertExpectations 断言每个路由配置有效且都被调用过，设置了 Times 的路由调用次数需一致，且没有未命中路由的请求
+func (m *Transport) AssertExpectations(t TestingT) bool {
+	t.Helper()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ok := true
+	for _, r := range m.routes {
+		switch {
+		case r.err != nil:
+			t.Errorf("mock: invalid route %s: %v", r, r.err)
+			ok = false
+		case r.times > 0 && r.calls != r.times:
+			t.Errorf("mock: expected %s to be called %d times, got %d", r, r.times, r.calls)
+			ok = false
+		case r.calls == 0:
+			t.Errorf("mock: expected %s to be called", r)
+			ok = false
+		}
+	}
+	for _, c := range m.calls {
+		if c.Route == nil {
+			t.Errorf("mock: unexpected call %s %s", c.Method, c.URL)
+			ok = false
+		}
+	}
+	return ok
+}
+
+func (m *Transport) countCalls(method, rawURL string) int {
+	probe := (&Transport{}).On(method, rawURL)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n := 0
+	for _, c := range m.calls {
+		if probe.matchRequest(c.Method, c.URL) {
+			n++
+		}
+	}
+	return n
+}
+
+func (m *Transport) describeCalls() string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	calls := make([]string, 0, len(m.calls))
+	for _, c := range m.calls {
+		calls = append(calls, c.Method+" "+c.URL.String())
+	}
+	return "[" + strings.Join(calls, ", ") + "]"
+}
+
+// Route 路由的匹配条件与预设响应
+type Route struct {
+	owner *Transport
+
+	// 匹配条件
+	method string
+	host   string
+	path   string
+	query  url.Values
+	header http.Header
+	bodies []func(body []byte) bool
+	times  int
+	calls  int
+	err    error
+
+	// 预设响应
+	status      int
+	replyHeader http.Header
+	replyBody   []byte
+	replyError  error
+	handler     func(req *http.Request) (*http.Response, error)
+}
+
+func (r *Route) String() string {
+	method := r.method
+	if method == "" {
+		method = "*"
+	}
+	return method + " " + r.host + r.path
+}
+
+// WithQuery 要求请求包含 query 参数 key=value
+func (r *Route) WithQuery(key, value string) *Route {
+	r.query.Add(key, value)
+	return r
+}
+
+// WithHeader 要求请求头 key 的值为 value
+func (r *Route) WithHeader(key, value string) *Route {
+	r.header.Add(key, value)
+	return r
+}
+
+// WithBody 要求请求 body 满足 match
+func (r *Route) WithBody(match func(body []byte) bool) *Route {
+	r.bodies = append(r.bodies, match)
+	return r
+}
+
+// WithBodyContains 要求请求 body 包含 s
+func (r *Route) WithBodyContains(s string) *Route {
+	return r.WithBody(func(body []byte) bool {
+		return bytes.Contains(body, []byte(s))
+	})
+}
+
+// WithJSONBody 要求请求 body 与 v 序列化后的 JSON 语义相等，忽略字段顺序与空白
+func (r *Route) WithJSONBody(v interface{}) *Route {
+	want, err := normalizeJSON(v)
+	if err != nil && r.err == nil {
+		r.err = err
+	}
+	return r.WithBody(func(body []byte) bool {
+		var got interface{}
+		if err := json.Unmarshal(body, &got); err != nil {
+			return false
+		}
+		return reflect.DeepEqual(got, want)
+	})
+}
+
+// Times 只匹配前 n 次请求，之后的请求继续匹配后续路由
+func (r *Route) Times(n int) *Route {
+	r.times = n
+	return r
+}
+
+// Once 等同于 Times(1)
+func (r *Route) Once() *Route {
+	return r.Times(1)
+}
+
+// Reply 设置响应状态码与 body
+func (r *Route) Reply(status int, body []byte) *Route {
+	r.status = status
+	r.replyBody = body
+	return r
+}
+
+// ReplyString 设置响应状态码与文本 body
+func (r *Route) ReplyString(status int, body string) *Route {
+	return r.Reply(status, []byte(body))
+}
+
+// ReplyJSON 设置响应状态码与 JSON body，并设置 Content-Type
+func (r *Route) ReplyJSON(status int, v interface{}) *Route {
+	body, err := json.Marshal(v)
+	if err != nil && r.err == nil {
+		r.err = fmt.Errorf("marshal reply: %w", err)
+	}
+	return r.ReplyHeader("Content-Type", "application/json").Reply(status, body)
+}
+
+// ReplyHeader 设置响应头
+func (r *Route) ReplyHeader(key, value string) *Route {
+	if r.replyHeader == nil {
+		r.replyHeader = http.Header{}
+	}
+	r.replyHeader.Add(key, value)
+	return r
+}
+
+// ReplyError 使请求返回 err，用于模拟网络错误
+func (r *Route) ReplyError(err error) *Route {
+	r.replyError = err
+	return r
+}
+
+// ReplyFunc 由 fn 生成响应
+func (r *Route) ReplyFunc(fn func(req *http.Request) (*http.Response, error)) *Route {
+	r.handler = fn
+	return r
+}
+
+// Calls 路由被命中的次数
+func (r *Route) Calls() int {
+	r.owner.mu.Lock()
+	defer r.owner.mu.Unlock()
+	return r.calls
+}
+
+func (r *Route) match(req *http.Request, body []byte) bool {
+	if (r.times > 0 && r.calls >= r.times) || !r.matchRequest(req.Method, req.URL) {
+		return false
+	}
+	for key, values := range r.header {
+		if !contains(req.Header.Values(key), values) {
+			return false
+		}
+	}
+	for _, match := range r.bodies {
+		if !match(body) {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *Route) matchRequest(method string, u *url.URL) bool {
+	if r.method != "" && r.method != "*" && r.method != method {
+		return false
+	}
+	if r.host != "" {
+		if ok, _ := path.Match(r.host, u.Scheme+"://"+u.Host); !ok {
+			return false
+		}
+	}
+	if r.path != "" {
+		if ok, _ := path.Match(r.path, u.Path); !ok {
+			return false
+		}
+	}
+	query := u.Query()
+	for key, values := range r.query {
+		if !contains(query[key], values) {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *Route) respond(req *http.Request) (*http.Response, error) {
+	if r.replyError != nil {
+		return nil, r.replyError
+	}
+	if r.handler != nil {
+		return r.handler(req)
+	}
+	return NewResponse(req, r.status, r.replyHeader, r.replyBody), nil
+}
+
+// NewResponse 构造响应，可在 ReplyFunc 中使用
+func NewResponse(req *http.Request, status int, header http.Header, body []byte) *http.Response {
+	if header == nil {
+		header = http.Header{}
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
+
+func contains(got, want []string) bool {
+	for _, w := range want {
+		found := false
+		for _, g := range got {
+			if g == w {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func normalizeJSON(v interface{}) (interface{}, error) {
+	var data []byte
+	switch b := v.(type) {
+	case []byte:
+		data = b
+	case string:
+		data = []byte(b)
+	default:
+		var err error
+		if data, err = json.Marshal(v); err != nil {
+			return nil, err
+		}
+	}
+	var out interface{}
+	if err := json.Unmarshal(data, &out); err != nil {
+		return nil, errors.New("invalid json body: " + err.Error())
+	}
+	return out, nil
+}
@@ -0,0 +1,227 @@
+package mock
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ffhuo/go-kits/gout"
+)
+
+type fakeT struct {
+	errors []string
+}
+
+func (t *fakeT) Helper() {}
+
+func (t *fakeT) Errorf(format string, args ...interface{}) {
+	t.errors = append(t.errors, fmt.Sprintf(format, args...))
+}
+
+func TestTransport(t *testing.T) {
+	m := New()
+	m.On("GET", "https://api.example.com/users/*").WithQuery("verbose", "1").
+		ReplyJSON(http.StatusOK, map[string]string{"name": "alice"})
+	m.On("POST", "/users").WithJSONBody(map[string]interface{}{"name": "bob", "age": 3}).
+		ReplyHeader("X-Id", "42").ReplyString(http.StatusCreated, "")
+	m.On("GET", "/flaky").Once().ReplyString(http.StatusServiceUnavailable, "")
+	m.On("GET", "/flaky").ReplyString(http.StatusOK, "ok")
+
+	var user struct{ Name string }
+	code, err := gout.New(m.Client()).GET("https://api.example.com/users/1").
+		SetQuery(map[string]string{"verbose": "1"}).BindJSON(&user).Do()
+	if err != nil || code != http.StatusOK || user.Name != "alice" {
+		t.Fatalf("GET users: code=%d err=%v user=%+v", code, err, user)
+	}
+
+	client := gout.NewClient(gout.WithTransport(m), gout.WithBaseURL("https://api.example.com"))
+	resp, err := client.POST("/users").SetJSON([]byte(`{"age":3,"name":"bob"}`)).DoResponse()
+	if err != nil || resp.StatusCode != http.StatusCreated || resp.Header.Get("X-Id") != "42" {
+		t.Fatalf("POST users: resp=%+v err=%v", resp, err)
+	}
+
+	resp, err = client.GET("/flaky").Retry(&gout.RetryPolicy{MaxAttempts: 2}).DoResponse()
+	if err != nil || resp.StatusCode != http.StatusOK || string(resp.Body) != "ok" || resp.Attempts != 2 {
+		t.Fatalf("GET flaky: resp=%+v err=%v", resp, err)
+	}
+
+	if _, err = client.GET("/missing").Do(); err == nil || !strings.Contains(err.Error(), "no route") {
+		t.Fatalf("expected no route error, got %v", err)
+	}
+
+	m.AssertCalled(t, "POST", "https://api.example.com/users")
+	m.AssertNotCalled(t, "DELETE", "/users")
+	if n := len(m.Calls()); n != 5 {
+		t.Fatalf("expected 5 calls, got %d", n)
+	}
+
+	ft := &fakeT{}
+	if m.AssertExpectations(ft) || len(ft.errors) != 1 || !strings.Contains(ft.errors[0], "/missing") {
+		t.Fatalf("expected unexpected call error, got %v", ft.errors)
+	}
+}
+
+func TestTransportInvalidRoute(t *testing.T) {
+	tests := []struct {
+		name  string
+		route func(m *Transport) *Route
+		err   string
+	}{
+		{"url", func(m *Transport) *Route { return m.On("GET", "http://[::1/users") }, "invalid url"},
+		{"json body", func(m *Transport) *Route { return m.On("POST", "/users").WithJSONBody("{bad") }, "invalid json body"},
+		{"reply json", func(m *Transport) *Route { return m.On("GET", "/users").ReplyJSON(http.StatusOK, func() {}) }, "marshal reply"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := New()
+			tt.route(m)
+			// 排在错误路由之后的路由同样不会被静默命中
+			m.On("", "/*").ReplyString(http.StatusOK, "ok")
+
+			if _, err := gout.New(m.Client()).POST("http://localhost/users").Do(); err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("expected invalid route error, got %v", err)
+			}
+			ft := &fakeT{}
+			if m.AssertExpectations(ft) || len(ft.errors) == 0 || !strings.Contains(ft.errors[0], tt.err) {
+				t.Fatalf("expected invalid route to be reported, got %v", ft.errors)
+			}
+		})
+	}
+}
+
+// Install 修改 http.DefaultClient，不能与其它测试并行
+func TestTransportInstall(t *testing.T) {
+	m := New()
+	m.On("", "/ping").ReplyError(errors.New("connection reset"))
+	restore := m.Install()
+	defer restore()
+
+	if _, err := gout.GET("http://localhost/ping").Do(); err == nil || !strings.Contains(err.Error(), "connection reset") {
+		t.Fatalf("expected mocked error, got %v", err)
+	}
+	m.AssertExpectations(t)
+}
+
+func TestRecorder(t *testing.T) {
+	var hits int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&hits, 1)
+		fmt.Fprintf(w, `{"hit":%d,"token":%q}`, n, r.URL.Query().Get("token"))
+	}))
+	defer srv.Close()
+
+	path := filepath.Join(t.TempDir(), "cassettes", "hits.json")
+	send := func(rec *Recorder) []int {
+		var hits []int
+		for i := 0; i < 2; i++ {
+			var res struct{ Hit int }
+			if _, err := gout.New(rec.Client()).GET(srv.URL + "/hits").
+				SetQuery(map[string]string{"token": "secret"}).BindJSON(&res).Do(); err != nil {
+				t.Fatal(err)
+			}
+			hits = append(hits, res.Hit)
+		}
+		return hits
+	}
+
+	rec, err := NewRecorder(path, ModeAuto, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !rec.Recording() {
+		t.Fatal("expected record mode without cassette")
+	}
+	rec.RedactQuery("token")
+	if got := send(rec); fmt.Sprint(got) != "[1 2]" {
+		t.Fatalf("record: got %v", got)
+	}
+	if err = rec.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(path); !strings.Contains(string(data), "token=REDACTED") {
+		t.Fatalf("expected redacted token in cassette: %s", data)
+	}
+
+	rec, err = NewRecorder(path, ModeAuto, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec.RedactQuery("token")
+	if got := send(rec); fmt.Sprint(got) != "[1 2]" || atomic.LoadInt32(&hits) != 2 {
+		t.Fatalf("replay: got %v, server hits %d", got, hits)
+	}
+	if _, err = gout.New(rec.Client()).GET(srv.URL + "/hits").Do(); err == nil {
+		t.Fatal("expected error for unrecorded request")
+	}
+
+	if _, err = NewRecorder(filepath.Join(t.TempDir(), "none.json"), ModeReplay, nil); err == nil {
+		t.Fatal("expected error for missing cassette in replay mode")
+	}
+	// 读取失败（此处为目录）时不当作 cassette 不存在
+	if _, err = NewRecorder(t.TempDir(), ModeAuto, nil); err == nil || !strings.Contains(err.Error(), "load cassette") {
+		t.Fatalf("expected load error in auto mode, got %v", err)
+	}
+}
+
+func TestRecorderCookieAndMultipart(t *testing.T) {
+	var hits int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "secret-session"})
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+		io.Copy(w, file)
+	}))
+	defer srv.Close()
+
+	path := filepath.Join(t.TempDir(), "upload.json")
+	upload := func(rec *Recorder) string {
+		var buf bytes.Buffer
+		mw := multipart.NewWriter(&buf)
+		fw, _ := mw.CreateFormFile("file", "a.txt")
+		fw.Write([]byte("hello"))
+		mw.Close()
+		resp, err := rec.Client().Post(srv.URL+"/upload", mw.FormDataContentType(), &buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return string(body)
+	}
+
+	rec, err := NewRecorder(path, ModeAuto, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := upload(rec); got != "hello" {
+		t.Fatalf("record: got %q", got)
+	}
+	if err = rec.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(path); strings.Contains(string(data), "secret-session") {
+		t.Fatalf("expected Set-Cookie to be redacted: %s", data)
+	}
+
+	// 回放时 multipart boundary 与录制时不同，仍应匹配
+	if rec, err = NewRecorder(path, ModeReplay, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := upload(rec); got != "hello" || atomic.LoadInt32(&hits) != 1 {
+		t.Fatalf("replay: got %q, server hits %d", got, hits)
+	}
+}
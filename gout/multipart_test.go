@@ -0,0 +1,122 @@
+package gout
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ffhuo/go-kits/gout/encode"
+)
+
+type memStorage map[string]string
+
+func (s memStorage) Download(ctx context.Context, path string) (io.ReadCloser, error) {
+	data, ok := s[path]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return io.NopCloser(strings.NewReader(data)), nil
+}
+
+func TestMultipart(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		forms []string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var parts []string
+		for k, v := range r.MultipartForm.Value {
+			parts = append(parts, k+"="+strings.Join(v, ","))
+		}
+		for k, files := range r.MultipartForm.File {
+			for _, fh := range files {
+				f, _ := fh.Open()
+				data, _ := io.ReadAll(f)
+				f.Close()
+				parts = append(parts, fmt.Sprintf("%s:%s:%s=%s", k, fh.Filename, fh.Header.Get("Content-Type"), data))
+			}
+		}
+		sort.Strings(parts)
+		mu.Lock()
+		forms = append(forms, strings.Join(parts, " "))
+		mu.Unlock()
+		// 流式编码使用 chunked 传输
+		if r.ContentLength != -1 {
+			http.Error(w, "expected chunked body", http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.txt")
+	if err := os.WriteFile(path, []byte("from disk"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	retry := &RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond}
+
+	tests := []struct {
+		name  string
+		flow  func() *DataFlow
+		forms []string
+	}{
+		{"files and fields", func() *DataFlow {
+			form := encode.NewMultipart().
+				Field("name", "bob").
+				FileFromPath("docs", path).
+				FileFromReader("docs", "b.json", strings.NewReader(`{"b":1}`)).
+				FileFromStorage("avatar", memStorage{"users/1.png": "png"}, "users/1.png", encode.WithFileName("me.png")).
+				FileFromReader("raw", "c", strings.NewReader("c"), encode.WithContentType("text/csv"))
+			return New().POST(srv.URL).SetMultipart(form).Retry(retry)
+		}, []string{
+			"avatar:me.png:image/png=png docs:a.txt:text/plain; charset=utf-8=from disk docs:b.json:application/json={\"b\":1} name=bob raw:c:text/csv=c",
+			"avatar:me.png:image/png=png docs:a.txt:text/plain; charset=utf-8=from disk docs:b.json:application/json={\"b\":1} name=bob raw:c:text/csv=c",
+		}},
+		{"form with multiple files", func() *DataFlow {
+			return New().POST(srv.URL).SetForm(map[string]string{"k": "v"}).
+				SetFormWithFile("f1", path).SetFormWithFile("f2", path)
+		}, []string{"f1:a.txt:text/plain; charset=utf-8=from disk f2:a.txt:text/plain; charset=utf-8=from disk k=v"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			forms = nil
+			code, err := tt.flow().Do()
+			if err != nil || code != http.StatusBadGateway {
+				t.Fatalf("unexpected result %d %v %v", code, err, forms)
+			}
+			if strings.Join(forms, "\n") != strings.Join(tt.forms, "\n") {
+				t.Fatalf("expected %q, got %q", tt.forms, forms)
+			}
+		})
+	}
+
+	// 不能 Seek 的 reader 只能发送一次，重试时编码失败
+	forms = nil
+	form := encode.NewMultipart().FileFromReader("f", "once.txt", onceReader{strings.NewReader("once")})
+	if _, err := New().POST(srv.URL).SetMultipart(form).Retry(retry).Do(); err == nil || !strings.Contains(err.Error(), "already been consumed") {
+		t.Fatalf("expected consumed reader error, got %v", err)
+	}
+	if len(forms) != 1 {
+		t.Fatalf("expected one upload, got %d", len(forms))
+	}
+
+	// 文件打开失败时不会重试
+	form = encode.NewMultipart().FileFromPath("f", filepath.Join(dir, "missing"))
+	if _, err := New().POST(srv.URL).SetMultipart(form).Retry(retry).Do(); err == nil {
+		t.Fatal("expected open error")
+	}
+}
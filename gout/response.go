@@ -0,0 +1,193 @@
+package gout
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// maxErrorBodySize StatusError 中保留的响应 body 上限
+const maxErrorBodySize = 64 << 10
+
+// Response 请求的响应信息
+type Response struct {
+	StatusCode int
+	Status     string
+	Header     http.Header
+	Cookies    []*http.Cookie
+	// Body 原始响应 body，通过 DoResponse 发送或状态码不符合 ExpectStatus 时读取
+	Body []byte
+
+	// URL 最终请求的地址，Redirects 为重定向前依次请求的地址
+	URL       string
+	Redirects []string
+	// Attempts 请求次数，开启重试时可能大于 1
+	Attempts int
+	// Latency 从发送请求到收到响应头的耗时（含重试等待），Duration 为读取完 body 的总耗时
+	Latency  time.Duration
+	Duration time.Duration
+}
+
+// IsSuccess 状态码是否为 2xx
+func (r *Response) IsSuccess() bool {
+	return isSuccess(r.StatusCode)
+}
+
+func newResponse(resp *http.Response, attempts int, latency time.Duration) *Response {
+	r := &Response{
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		Header:     resp.Header,
+		Cookies:    resp.Cookies(),
+		Attempts:   attempts,
+		Latency:    latency,
+	}
+	if resp.Request != nil {
+		r.URL = resp.Request.URL.String()
+		// http.Client 跟随重定向时，每个请求的 Response 指向触发它的重定向响应
+		for req := resp.Request; req.Response != nil && req.Response.Request != nil; {
+			req = req.Response.Request
+			r.Redirects = append([]string{req.URL.String()}, r.Redirects...)
+		}
+	}
+	return r
+}
+
+// StatusError 响应状态码不符合 ExpectStatus/ExpectSuccess 时返回的错误
+type StatusError struct {
+	Method string
+	// URL 请求地址，userinfo 及 query 参数值已脱敏
+	URL        string
+	StatusCode int
+	Status     string
+	Header     http.Header
+	// Body 响应 body，最多保留 64KB
+	Body []byte
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("gout: %s %s: unexpected status %s", e.Method, e.URL, e.Status)
+}
+
+// redactURL 返回用于 trace 及错误信息的 URL，按 OpenTelemetry 语义约定将 userinfo 及 query 参数值替换为 REDACTED，
+// 避免 token、签名等凭据泄露
+func redactURL(u *url.URL) string {
+	r := *u
+	if r.User != nil {
+		r.User = url.UserPassword("REDACTED", "REDACTED")
+	}
+	if r.RawQuery != "" {
+		params := strings.Split(r.RawQuery, "&")
+		for i, param := range params {
+			if key, _, ok := strings.Cut(param, "="); ok {
+				params[i] = key + "=REDACTED"
+			}
+		}
+		r.RawQuery = strings.Join(params, "&")
+	}
+	return r.String()
+}
+
+// ExpectStatus 响应状态码不在 codes 中时返回 *StatusError，设置了错误解码目标时仍会解码错误 body
+func (d *DataFlow) ExpectStatus(codes ...int) *DataFlow {
+	d.expect = func(status int) bool {
+		for _, code := range codes {
+			if status == code {
+				return true
+			}
+		}
+		return false
+	}
+	return d
+}
+
+// ExpectSuccess 响应状态码不是 2xx 时返回 *StatusError
+func (d *DataFlow) ExpectSuccess() *DataFlow {
+	d.expect = isSuccess
+	return d
+}
+
+func isSuccess(status int) bool {
+	return status >= http.StatusOK && status < http.StatusMultipleChoices
+}
+
+// DoResponse 发送请求并返回完整的响应信息，响应 body 会被读入 Response.Body 后再按 Bind 解码。
+// 请求已收到响应时，即使返回错误（如 *StatusError 或解码失败）Response 也不为 nil。
+func (d *DataFlow) DoResponse() (*Response, error) {
+	return d.do(true)
+}
+
+func (d *DataFlow) do(capture bool) (*Response, error) {
+	if d.Err != nil {
+		return nil, d.Err
+	}
+
+	defer d.Reset()
+
+	start := time.Now()
+	resp, done, attempts, err := d.roundTrip()
+	if err != nil {
+		d.Err = err
+		return nil, err
+	}
+
+	d.resp = resp
+	defer func() {
+		resp.Body.Close()
+		resp.Close = true
+		done()
+	}()
+
+	result := newResponse(resp, attempts, time.Since(start))
+	d.last = result
+	defer func() {
+		result.Duration = time.Since(start)
+	}()
+
+	body := io.Reader(resp.Body)
+	if capture {
+		if result.Body, err = io.ReadAll(resp.Body); err != nil {
+			d.Err = err
+			return result, err
+		}
+		body = bytes.NewReader(result.Body)
+	}
+
+	if d.expect != nil && !d.expect(resp.StatusCode) {
+		if !capture {
+			result.Body, _ = io.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+			body = bytes.NewReader(result.Body)
+		}
+		if d.errDecoder != nil {
+			d.decodeBody(resp, body)
+		}
+		d.Err = d.statusError(resp, result.Body)
+		return result, d.Err
+	}
+
+	if d.Err = d.decodeBody(resp, body); d.Err != nil {
+		return result, d.Err
+	}
+	return result, nil
+}
+
+func (d *DataFlow) statusError(resp *http.Response, body []byte) *StatusError {
+	if len(body) > maxErrorBodySize {
+		body = body[:maxErrorBodySize]
+	}
+	err := &StatusError{
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		Header:     resp.Header,
+		Body:       body,
+	}
+	if resp.Request != nil {
+		err.Method = resp.Request.Method
+		err.URL = redactURL(resp.Request.URL)
+	}
+	return err
+}
@@ -0,0 +1,320 @@
+package gout
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy 请求重试策略。按状态码重试对所有方法生效；请求错误默认只有幂等请求
+// （GET/HEAD/OPTIONS/TRACE/PUT/DELETE 或带 Idempotency-Key 请求头）全部重试，
+// 其它请求只在连接未建立时重试，避免服务端已收到请求后重复提交。构建请求或编码请求体失败时不重试。
+type RetryPolicy struct {
+	// MaxAttempts 最大尝试次数（含首次请求），小于等于 1 时不重试
+	MaxAttempts int
+	// BaseDelay 首次重试前的等待时间，之后按 Multiplier 指数增长
+	BaseDelay time.Duration
+	// MaxDelay 单次等待时间上限
+	MaxDelay time.Duration
+	// Multiplier 退避倍数，小于 1 时按 2 处理
+	Multiplier float64
+	// Jitter 随机抖动比例 [0, 1]，等待时间在 [delay*(1-Jitter), delay] 间随机
+	Jitter float64
+	// RetryOnStatus 响应状态码是否需要重试，为 nil 时使用 DefaultRetryOnStatus
+	RetryOnStatus func(status int) bool
+	// RetryOnError 请求错误是否需要重试，为 nil 时幂等请求均重试，非幂等请求只重试建立连接失败的错误
+	RetryOnError func(err error) bool
+	// RespectRetryAfter 按响应的 Retry-After 头等待，超过 MaxRetryAfter 时不再重试
+	RespectRetryAfter bool
+	MaxRetryAfter     time.Duration
+
+	// HedgeDelay 大于 0 时对 GET/HEAD 启用对冲请求：
+	// 首个请求 HedgeDelay 内未返回则并发发起下一个，取最先成功的响应
+	HedgeDelay time.Duration
+	// MaxHedged 额外对冲请求数，HedgeDelay 大于 0 时默认为 1
+	MaxHedged int
+}
+
+// DefaultRetryPolicy 默认重试策略：最多 3 次，100ms 起指数退避，遵循 Retry-After
+func DefaultRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts:       3,
+		BaseDelay:         100 * time.Millisecond,
+		MaxDelay:          5 * time.Second,
+		Multiplier:        2,
+		Jitter:            0.2,
+		RespectRetryAfter: true,
+		MaxRetryAfter:     30 * time.Second,
+	}
+}
+
+// DefaultRetryOnStatus 429 及 502/503/504 需要重试
+func DefaultRetryOnStatus(status int) bool {
+	switch status {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// RetryOnStatus 返回匹配指定状态码的重试判断
+func RetryOnStatus(codes ...int) func(status int) bool {
+	return func(status int) bool {
+		for _, code := range codes {
+			if status == code {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Retry 为本次请求设置重试策略，policy 为 nil 时使用 DefaultRetryPolicy
+func (d *DataFlow) Retry(policy *RetryPolicy) *DataFlow {
+	if policy == nil {
+		policy = DefaultRetryPolicy()
+	}
+	d.retry = policy
+	return d
+}
+
+func (p *RetryPolicy) retryStatus(status int) bool {
+	if p.RetryOnStatus != nil {
+		return p.RetryOnStatus(status)
+	}
+	return DefaultRetryOnStatus(status)
+}
+
+func (p *RetryPolicy) retryError(err error, idempotent bool) bool {
+	var reqErr *requestError
+	if errors.As(err, &reqErr) {
+		return false
+	}
+	if p.RetryOnError != nil {
+		return p.RetryOnError(err)
+	}
+	return idempotent || connectError(err)
+}
+
+// connectError 连接建立前的错误，此时服务端不可能收到请求
+func connectError(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && (opErr.Op == "dial" || opErr.Op == "proxyconnect")
+}
+
+// requestError 构建请求或编码请求体失败，重发也会失败，不重试
+type requestError struct {
+	err error
+}
+
+func (e *requestError) Error() string {
+	return e.err.Error()
+}
+
+func (e *requestError) Unwrap() error {
+	return e.err
+}
+
+// idempotent 请求是否可以安全重发，规则与 net/http 一致
+func (d *DataFlow) idempotent() bool {
+	method := d.method
+	if method == "" && d.req != nil {
+		method = d.req.Method
+	}
+	switch method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+
+	keys := map[string]bool{}
+	if d.owner != nil {
+		for k := range d.owner.header {
+			keys[http.CanonicalHeaderKey(k)] = true
+		}
+	}
+	for k := range d.headerEncoder {
+		keys[http.CanonicalHeaderKey(k)] = true
+	}
+	if d.req != nil {
+		for k := range d.req.Header {
+			keys[k] = true
+		}
+	}
+	return keys["Idempotency-Key"] || keys["X-Idempotency-Key"]
+}
+
+// backoff 第 attempt 次请求失败后的等待时间
+func (p *RetryPolicy) backoff(attempt int) time.Duration {
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = 2
+	}
+	delay := float64(p.BaseDelay) * math.Pow(multiplier, float64(attempt-1))
+	if p.MaxDelay > 0 && delay > float64(p.MaxDelay) {
+		delay = float64(p.MaxDelay)
+	}
+	if p.Jitter > 0 {
+		delay -= delay * math.Min(p.Jitter, 1) * rand.Float64()
+	}
+	return time.Duration(delay)
+}
+
+// wait 计算下一次重试前的等待时间，返回 false 表示不应再重试
+func (p *RetryPolicy) wait(attempt int, resp *http.Response) (time.Duration, bool) {
+	delay := p.backoff(attempt)
+	if resp == nil || !p.RespectRetryAfter {
+		return delay, true
+	}
+	retryAfter, ok := parseRetryAfter(resp.Header.Get("Retry-After"))
+	if !ok {
+		return delay, true
+	}
+	if p.MaxRetryAfter > 0 && retryAfter > p.MaxRetryAfter {
+		return 0, false
+	}
+	return max(delay, retryAfter), true
+}
+
+func (p *RetryPolicy) hedged(method string) int {
+	if p.HedgeDelay <= 0 || (method != http.MethodGet && method != http.MethodHead) {
+		return 0
+	}
+	if p.MaxHedged <= 0 {
+		return 1
+	}
+	return p.MaxHedged
+}
+
+// parseRetryAfter 解析秒数或 HTTP 日期格式的 Retry-After
+func parseRetryAfter(v string) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(v); err == nil {
+		return time.Duration(max(seconds, 0)) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return max(time.Until(t), 0), true
+	}
+	return 0, false
+}
+
+// discard 丢弃不再使用的响应，使连接可以被复用
+func discard(resp *http.Response, done context.CancelFunc) {
+	if resp != nil {
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+		resp.Body.Close()
+	}
+	if done != nil {
+		done()
+	}
+}
+
+// sleep 等待 delay，ctx 结束时提前返回错误
+func sleep(ctx context.Context, delay time.Duration) error {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+type hedgeResult struct {
+	index int
+	resp  *http.Response
+	done  context.CancelFunc
+	err   error
+}
+
+// hedge 并发发起至多 1+extra 个请求，返回最先成功的响应，其余请求被取消
+func (d *DataFlow) hedge(parent context.Context, policy *RetryPolicy, attempt, extra int) (*http.Response, context.CancelFunc, error) {
+	results := make(chan hedgeResult, extra+1)
+	cancels := make([]context.CancelFunc, 0, extra+1)
+	launch := func() {
+		ctx, cancel := context.WithCancel(parent)
+		index := len(cancels)
+		cancels = append(cancels, cancel)
+		go func() {
+			resp, done, err := d.send(ctx, attempt-1+index)
+			results <- hedgeResult{index: index, resp: resp, done: done, err: err}
+		}()
+	}
+	finish := func(r hedgeResult) context.CancelFunc {
+		return func() {
+			if r.done != nil {
+				r.done()
+			}
+			cancels[r.index]()
+		}
+	}
+
+	launch()
+	timer := time.NewTimer(policy.HedgeDelay)
+	defer timer.Stop()
+
+	var last *hedgeResult
+	for pending := 1; pending > 0; {
+		select {
+		case r := <-results:
+			pending--
+			var reqErr *requestError
+			if errors.As(r.err, &reqErr) {
+				for _, cancel := range cancels {
+					cancel()
+				}
+				go func(n int) {
+					for ; n > 0; n-- {
+						loser := <-results
+						discard(loser.resp, loser.done)
+					}
+				}(pending)
+				return nil, nil, r.err
+			}
+			if r.err == nil && !policy.retryStatus(r.resp.StatusCode) {
+				for i, cancel := range cancels {
+					if i != r.index {
+						cancel()
+					}
+				}
+				go func(n int) {
+					for ; n > 0; n-- {
+						loser := <-results
+						discard(loser.resp, loser.done)
+					}
+				}(pending)
+				return r.resp, finish(r), nil
+			}
+			if last != nil {
+				discard(last.resp, finish(*last))
+			}
+			last = &r
+			// 已完成的请求均失败时不再等待，立即发起下一个
+			if pending == 0 && len(cancels) <= extra {
+				launch()
+				pending++
+				timer.Reset(policy.HedgeDelay)
+			}
+		case <-timer.C:
+			if len(cancels) <= extra {
+				launch()
+				pending++
+				timer.Reset(policy.HedgeDelay)
+			}
+		}
+	}
+
+	if last.err != nil {
+		cancels[last.index]()
+		return nil, nil, last.err
+	}
+	return last.resp, finish(*last), nil
+}
@@ -0,0 +1,296 @@
+package gout
+
+import (
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  RetryPolicy
+		attempt int
+		want    time.Duration
+	}{
+		{"first", RetryPolicy{BaseDelay: 100 * time.Millisecond, Multiplier: 2}, 1, 100 * time.Millisecond},
+		{"exponential", RetryPolicy{BaseDelay: 100 * time.Millisecond, Multiplier: 3}, 3, 900 * time.Millisecond},
+		{"default multiplier", RetryPolicy{BaseDelay: 100 * time.Millisecond}, 3, 400 * time.Millisecond},
+		{"max delay", RetryPolicy{BaseDelay: 100 * time.Millisecond, Multiplier: 2, MaxDelay: 300 * time.Millisecond}, 5, 300 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.backoff(tt.attempt); got != tt.want {
+				t.Errorf("expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+
+	p := RetryPolicy{BaseDelay: 100 * time.Millisecond, Jitter: 0.5}
+	for i := 0; i < 100; i++ {
+		if got := p.backoff(1); got < 50*time.Millisecond || got > 100*time.Millisecond {
+			t.Fatalf("jitter out of range: %s", got)
+		}
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Duration
+		ok    bool
+	}{
+		{"", 0, false},
+		{"2", 2 * time.Second, true},
+		{"-1", 0, true},
+		{"soon", 0, false},
+		{time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat), 0, true},
+	}
+	for _, tt := range tests {
+		if got, ok := parseRetryAfter(tt.value); got != tt.want || ok != tt.ok {
+			t.Errorf("parseRetryAfter(%q) = %s %v, want %s %v", tt.value, got, ok, tt.want, tt.ok)
+		}
+	}
+	if got, ok := parseRetryAfter(time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)); !ok || got < 59*time.Minute {
+		t.Errorf("expected about an hour for http date, got %s %v", got, ok)
+	}
+
+	policy := &RetryPolicy{BaseDelay: 100 * time.Millisecond, RespectRetryAfter: true, MaxRetryAfter: 10 * time.Second}
+	resp := func(v string) *http.Response {
+		return &http.Response{Header: http.Header{"Retry-After": []string{v}}}
+	}
+	waits := []struct {
+		name string
+		resp *http.Response
+		want time.Duration
+		ok   bool
+	}{
+		{"no response", nil, 100 * time.Millisecond, true},
+		{"no header", &http.Response{Header: http.Header{}}, 100 * time.Millisecond, true},
+		{"longer than backoff", resp("3"), 3 * time.Second, true},
+		{"shorter than backoff", resp("0"), 100 * time.Millisecond, true},
+		{"exceeds max", resp("60"), 0, false},
+	}
+	for _, tt := range waits {
+		if got, ok := policy.wait(1, tt.resp); got != tt.want || ok != tt.ok {
+			t.Errorf("%s: expected %s %v, got %s %v", tt.name, tt.want, tt.ok, got, ok)
+		}
+	}
+}
+
+func TestRetryStatus(t *testing.T) {
+	var hits int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&hits, 1)
+		switch {
+		case r.URL.Path == "/retry-after" && n == 1:
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+		case r.URL.Path == "/too-long":
+			w.Header().Set("Retry-After", "120")
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case r.URL.Path == "/flaky" && n < 3:
+			w.WriteHeader(http.StatusBadGateway)
+		case r.URL.Path == "/not-found":
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			io.WriteString(w, "ok")
+		}
+	}))
+	defer srv.Close()
+
+	policy := DefaultRetryPolicy()
+	policy.BaseDelay = time.Millisecond
+	tests := []struct {
+		path     string
+		status   int
+		attempts int
+		minWait  time.Duration
+	}{
+		{"/flaky", http.StatusOK, 3, 0},
+		{"/retry-after", http.StatusOK, 2, time.Second},
+		// Retry-After 超过 MaxRetryAfter 时直接返回
+		{"/too-long", http.StatusServiceUnavailable, 1, 0},
+		{"/not-found", http.StatusNotFound, 1, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			atomic.StoreInt32(&hits, 0)
+			start := time.Now()
+			resp, err := New().POST(srv.URL + tt.path).Retry(policy).DoResponse()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.status || resp.Attempts != tt.attempts {
+				t.Fatalf("expected %d after %d attempts, got %d after %d", tt.status, tt.attempts, resp.StatusCode, resp.Attempts)
+			}
+			if elapsed := time.Since(start); elapsed < tt.minWait {
+				t.Fatalf("expected to wait at least %s, got %s", tt.minWait, elapsed)
+			}
+		})
+	}
+}
+
+func TestHedge(t *testing.T) {
+	var (
+		hits     int32
+		canceled = make(chan struct{}, 1)
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&hits, 1) == 1 && r.URL.Path == "/slow-first" {
+			select {
+			case <-r.Context().Done():
+				canceled <- struct{}{}
+			case <-time.After(2 * time.Second):
+			}
+			io.WriteString(w, "slow")
+			return
+		}
+		if r.URL.Path == "/unavailable" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		io.WriteString(w, "fast")
+	}))
+	defer srv.Close()
+
+	policy := &RetryPolicy{MaxAttempts: 1, HedgeDelay: 50 * time.Millisecond}
+	tests := []struct {
+		name   string
+		flow   func() *DataFlow
+		status int
+		body   string
+		hits   int32
+	}{
+		{"hedged", func() *DataFlow { return New().GET(srv.URL + "/slow-first") }, http.StatusOK, "fast", 2},
+		{"first wins", func() *DataFlow { return New().GET(srv.URL + "/fast") }, http.StatusOK, "fast", 1},
+		// 已完成的请求失败时立即发起对冲请求，都失败时返回最后一个响应
+		{"all failed", func() *DataFlow { return New().GET(srv.URL + "/unavailable") }, http.StatusServiceUnavailable, "", 2},
+		// 只对 GET/HEAD 对冲
+		{"post", func() *DataFlow { return New().POST(srv.URL + "/fast") }, http.StatusOK, "fast", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			atomic.StoreInt32(&hits, 0)
+			start := time.Now()
+			resp, err := tt.flow().Retry(policy).DoResponse()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.status || string(resp.Body) != tt.body {
+				t.Fatalf("expected %d %q, got %d %q", tt.status, tt.body, resp.StatusCode, resp.Body)
+			}
+			if elapsed := time.Since(start); elapsed > time.Second {
+				t.Fatalf("hedged request took %s", elapsed)
+			}
+			if got := atomic.LoadInt32(&hits); got != tt.hits {
+				t.Fatalf("expected %d server hits, got %d", tt.hits, got)
+			}
+		})
+	}
+
+	// 落后的请求会被取消
+	select {
+	case <-canceled:
+	case <-time.After(time.Second):
+		t.Fatal("expected slow request to be canceled")
+	}
+}
+
+func TestRetryOnError(t *testing.T) {
+	var hits int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		time.Sleep(100 * time.Millisecond)
+	}))
+	defer srv.Close()
+
+	// 监听后立即关闭，连接会被拒绝
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	refused := "http://" + l.Addr().String()
+	l.Close()
+
+	policy := &RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond}
+	tests := []struct {
+		name     string
+		flow     func() *DataFlow
+		attempts int
+		hits     int32
+	}{
+		{"idempotent timeout", func() *DataFlow {
+			return New().GET(srv.URL).SetTimeout(20 * time.Millisecond)
+		}, 3, 3},
+		{"post timeout", func() *DataFlow {
+			return New().POST(srv.URL).SetTimeout(20 * time.Millisecond)
+		}, 1, 1},
+		{"post with idempotency key", func() *DataFlow {
+			return New().POST(srv.URL).SetHeader(map[string]string{"Idempotency-Key": "k"}).SetTimeout(20 * time.Millisecond)
+		}, 3, 3},
+		{"post dial error", func() *DataFlow {
+			return New().POST(refused)
+		}, 3, 0},
+		// 构建失败时不会进入拦截器
+		{"encode error", func() *DataFlow {
+			return New().GET(srv.URL).SetJSON([]byte("{invalid"))
+		}, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			atomic.StoreInt32(&hits, 0)
+			var attempts int
+			d := tt.flow().Retry(policy).Use(func(req *http.Request, next Doer) (*http.Response, error) {
+				attempts++
+				return next.Do(req)
+			})
+			if _, err := d.Do(); err == nil {
+				t.Fatal("expected error")
+			}
+			if attempts != tt.attempts {
+				t.Errorf("expected %d attempts, got %d", tt.attempts, attempts)
+			}
+			if got := atomic.LoadInt32(&hits); got != tt.hits {
+				t.Errorf("expected %d server hits, got %d", tt.hits, got)
+			}
+		})
+	}
+
+	var reqErr *requestError
+	if _, err := New().GET(srv.URL).SetJSON([]byte("{invalid")).Retry(policy).Do(); !errors.As(err, &reqErr) {
+		t.Errorf("expected request build error, got %v", err)
+	}
+}
+
+func TestRetrySetRequestBody(t *testing.T) {
+	var bodies []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	policy := &RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond}
+
+	// body 没有 GetBody 时只发送一次
+	req, _ := http.NewRequest(http.MethodPost, srv.URL, io.NopCloser(strings.NewReader("once")))
+	if code, _ := New().SetRequest(req).Retry(policy).Do(); code != http.StatusServiceUnavailable || len(bodies) != 1 {
+		t.Fatalf("expected single attempt, got %d %v", code, bodies)
+	}
+
+	// 有 GetBody 时每次重试都发送完整 body
+	bodies = nil
+	req, _ = http.NewRequest(http.MethodPut, srv.URL, strings.NewReader("again"))
+	New().SetRequest(req).Retry(policy).Do()
+	if strings.Join(bodies, ",") != "again,again,again" {
+		t.Fatalf("expected body to be replayed, got %v", bodies)
+	}
+}
@@ -0,0 +1,251 @@
+package gout
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ffhuo/go-kits/common/bar"
+)
+
+// ErrStopStream 在 NDJSON/SSE 回调中返回该错误可提前结束读取，不会作为错误返回
+var ErrStopStream = errors.New("gout: stop stream")
+
+// SetBodyStream 以流的方式发送请求体，不会预先读入内存。size 大于等于 0 时作为 Content-Length，
+// 小于 0 时使用 chunked 传输。body 实现 io.Seeker 时重试会从起始位置重新发送，否则不重试。
+func (d *DataFlow) SetBodyStream(body io.Reader, size int64) *DataFlow {
+	d.stream = body
+	d.streamSize = size
+	if seeker, ok := body.(io.Seeker); ok {
+		start, err := seeker.Seek(0, io.SeekCurrent)
+		if err != nil {
+			// 不覆盖之前记录的错误
+			if d.Err == nil {
+				d.Err = err
+			}
+		} else {
+			d.streamStart = start
+		}
+	}
+	return d
+}
+
+// Stream 发送请求并返回未读取的响应 body，调用方读取完毕后必须 Close，状态码不符合 ExpectStatus 时返回 *StatusError。
+// 未通过 SetTimeout 或 Client 设置超时时不限制读取时长，长连接应通过 WithContext 控制生命周期。
+func (d *DataFlow) Stream() (int, io.ReadCloser, error) {
+	if d.Err != nil {
+		return 0, nil, d.Err
+	}
+
+	defer d.Reset()
+
+	d.streaming = true
+	start := time.Now()
+	resp, done, attempts, err := d.roundTrip()
+	if err != nil {
+		d.Err = err
+		return 0, nil, err
+	}
+	d.last = newResponse(resp, attempts, time.Since(start))
+
+	if d.expect != nil && !d.expect(resp.StatusCode) {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+		discard(resp, done)
+		d.last.Body = body
+		d.Err = d.statusError(resp, body)
+		return resp.StatusCode, nil, d.Err
+	}
+	return resp.StatusCode, &streamBody{ReadCloser: resp.Body, done: done}, nil
+}
+
+// NDJSON 逐行读取换行分隔的 JSON 响应，每行调用一次 fn，空行会被跳过。未设置 ExpectStatus 时非 2xx 响应返回 *StatusError。
+func (d *DataFlow) NDJSON(fn func(line []byte) error) (int, error) {
+	return d.readStream(func(r io.Reader) error {
+		return ReadNDJSON(r, fn)
+	})
+}
+
+// SSE 按 text/event-stream 格式读取响应，每个事件调用一次 fn。未设置 ExpectStatus 时非 2xx 响应返回 *StatusError。
+func (d *DataFlow) SSE(fn func(event *Event) error) (int, error) {
+	if d.headerEncoder["Accept"] == "" {
+		d.AddHeader("Accept", "text/event-stream")
+	}
+	return d.readStream(func(r io.Reader) error {
+		return ReadSSE(r, fn)
+	})
+}
+
+func (d *DataFlow) readStream(read func(r io.Reader) error) (int, error) {
+	if d.expect == nil {
+		d.ExpectSuccess()
+	}
+	status, body, err := d.Stream()
+	if err != nil {
+		return status, err
+	}
+	defer body.Close()
+
+	if err = read(body); err != nil && !errors.Is(err, ErrStopStream) {
+		return status, err
+	}
+	return status, nil
+}
+
+// ReadNDJSON 从 r 逐行读取换行分隔的 JSON，每行调用一次 fn
+func ReadNDJSON(r io.Reader, fn func(line []byte) error) error {
+	reader := bufio.NewReader(r)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			if ferr := fn(line); ferr != nil {
+				return ferr
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// Event Server-Sent Events 事件
+type Event struct {
+	ID    string
+	Event string
+	Data  string
+	// Retry 服务端建议的重连间隔，未设置时为 0
+	Retry time.Duration
+}
+
+// ReadSSE 从 r 读取 text/event-stream 格式的事件，每个事件调用一次 fn
+func ReadSSE(r io.Reader, fn func(event *Event) error) error {
+	reader := bufio.NewReader(r)
+	event := &Event{}
+	var data []string
+	dispatch := func() error {
+		if len(data) == 0 {
+			event = &Event{ID: event.ID}
+			return nil
+		}
+		event.Data = strings.Join(data, "\n")
+		err := fn(event)
+		event, data = &Event{ID: event.ID}, nil
+		return err
+	}
+
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if err == io.EOF && line == "" {
+			// 流结束时未以空行结尾的事件不分发
+			return nil
+		}
+		line = strings.TrimRight(line, "\r\n")
+
+		if line == "" {
+			if derr := dispatch(); derr != nil {
+				return derr
+			}
+		} else if !strings.HasPrefix(line, ":") {
+			name, value, _ := strings.Cut(line, ":")
+			value = strings.TrimPrefix(value, " ")
+			switch name {
+			case "id":
+				event.ID = value
+			case "event":
+				event.Event = value
+			case "data":
+				data = append(data, value)
+			case "retry":
+				if ms, perr := strconv.Atoi(value); perr == nil {
+					event.Retry = time.Duration(ms) * time.Millisecond
+				}
+			}
+		}
+
+		if err == io.EOF {
+			return nil
+		}
+	}
+}
+
+// streamBody 关闭时同时释放请求 ctx
+type streamBody struct {
+	io.ReadCloser
+	done context.CancelFunc
+}
+
+func (b *streamBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.done()
+	return err
+}
+
+// ProgressFunc 传输进度回调，total 未知时为 -1
+type ProgressFunc func(current, total int64)
+
+// UploadProgress 设置请求体发送进度回调
+func (d *DataFlow) UploadProgress(fn ProgressFunc) *DataFlow {
+	d.uploadProgress = fn
+	return d
+}
+
+// DownloadProgress 设置响应体读取进度回调
+func (d *DataFlow) DownloadProgress(fn ProgressFunc) *DataFlow {
+	d.downloadProgress = fn
+	return d
+}
+
+// BarProgress 使用 common/bar 在终端显示进度条，total 未知时不显示
+func BarProgress(prefix string) ProgressFunc {
+	var (
+		b        *bar.Bar
+		finished bool
+	)
+	return func(current, total int64) {
+		if total <= 0 || finished {
+			return
+		}
+		if b == nil {
+			b = bar.New(0, total, prefix)
+		}
+		if current >= total {
+			finished = true
+			b.Finish()
+			return
+		}
+		b.Play(current)
+	}
+}
+
+type progressReader struct {
+	io.ReadCloser
+	current int64
+	total   int64
+	fn      ProgressFunc
+}
+
+func newProgressReader(body io.ReadCloser, total int64, fn ProgressFunc) io.ReadCloser {
+	if total <= 0 {
+		total = -1
+	}
+	return &progressReader{ReadCloser: body, total: total, fn: fn}
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.ReadCloser.Read(b)
+	if n > 0 {
+		p.current += int64(n)
+		p.fn(p.current, p.total)
+	}
+	return n, err
+}
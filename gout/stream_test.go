@@ -0,0 +1,289 @@
+package gout
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestReadSSE(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		events []Event
+	}{
+		{"single", "data: hello\n\n", []Event{{Data: "hello"}}},
+		{"multi-line data", "data: a\ndata: b\ndata:c\n\n", []Event{{Data: "a\nb\nc"}}},
+		{"fields", "id: 1\nevent: update\nretry: 1500\ndata: x\n\n", []Event{{ID: "1", Event: "update", Data: "x", Retry: 1500 * time.Millisecond}}},
+		{"id carried over", "id: 7\ndata: a\n\ndata: b\n\n", []Event{{ID: "7", Data: "a"}, {ID: "7", Data: "b"}}},
+		{"comments and crlf", ": ping\r\ndata: a\r\n\r\n", []Event{{Data: "a"}}},
+		{"invalid retry", "retry: soon\ndata: a\n\n", []Event{{Data: "a"}}},
+		{"empty event skipped", "event: noop\n\ndata: a\n\n", []Event{{Data: "a"}}},
+		// 流结束时未以空行结尾的事件不分发
+		{"missing trailing blank line", "data: a\n\ndata: partial", []Event{{Data: "a"}}},
+		{"missing trailing newline", "data: a\n\ndata: partial\n", []Event{{Data: "a"}}},
+		{"empty", "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var events []Event
+			err := ReadSSE(strings.NewReader(tt.input), func(e *Event) error {
+				events = append(events, *e)
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(events, tt.events) {
+				t.Fatalf("expected %+v, got %+v", tt.events, events)
+			}
+		})
+	}
+
+	stop := errors.New("stop")
+	var n int
+	err := ReadSSE(strings.NewReader("data: a\n\ndata: b\n\n"), func(e *Event) error {
+		n++
+		return stop
+	})
+	if err != stop || n != 1 {
+		t.Fatalf("expected callback error after first event, got %v after %d", err, n)
+	}
+}
+
+func TestReadNDJSON(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		lines []string
+	}{
+		{"lines", "{\"a\":1}\n{\"a\":2}\n", []string{`{"a":1}`, `{"a":2}`}},
+		{"blank lines and crlf", "\n{\"a\":1}\r\n\n  \n{\"a\":2}\r\n", []string{`{"a":1}`, `{"a":2}`}},
+		{"missing trailing newline", "{\"a\":1}\n{\"a\":2}", []string{`{"a":1}`, `{"a":2}`}},
+		{"empty", "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var lines []string
+			if err := ReadNDJSON(strings.NewReader(tt.input), func(line []byte) error {
+				lines = append(lines, string(line))
+				return nil
+			}); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(lines, tt.lines) {
+				t.Fatalf("expected %q, got %q", tt.lines, lines)
+			}
+		})
+	}
+}
+
+func TestSSE(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/forbidden" {
+			http.Error(w, "no access", http.StatusForbidden)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprintf(w, "data: %s\n\n", r.Header.Get("Accept"))
+		w.(http.Flusher).Flush()
+		for i := 1; i <= 3; i++ {
+			fmt.Fprintf(w, "id: %d\ndata: {\"n\":%d}\n\n", i, i)
+			w.(http.Flusher).Flush()
+		}
+	}))
+	defer srv.Close()
+
+	var data []string
+	code, err := New().GET(srv.URL).SSE(func(e *Event) error {
+		data = append(data, e.Data)
+		if e.ID == "2" {
+			return ErrStopStream
+		}
+		return nil
+	})
+	if err != nil || code != http.StatusOK {
+		t.Fatal(code, err)
+	}
+	if want := []string{"text/event-stream", `{"n":1}`, `{"n":2}`}; !reflect.DeepEqual(data, want) {
+		t.Fatalf("expected %q, got %q", want, data)
+	}
+
+	var statusErr *StatusError
+	code, err = New().GET(srv.URL + "/forbidden").SSE(func(e *Event) error { return nil })
+	if !errors.As(err, &statusErr) || code != http.StatusForbidden || !strings.Contains(string(statusErr.Body), "no access") {
+		t.Fatalf("expected status error, got %d %v", code, err)
+	}
+}
+
+func TestNDJSON(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "{\"n\":1}\n\n{\"n\":2}")
+	}))
+	defer srv.Close()
+
+	var lines []string
+	code, err := New().GET(srv.URL).NDJSON(func(line []byte) error {
+		lines = append(lines, string(line))
+		return nil
+	})
+	if err != nil || code != http.StatusOK || strings.Join(lines, ",") != `{"n":1},{"n":2}` {
+		t.Fatalf("unexpected result %d %v %q", code, err, lines)
+	}
+}
+
+// onceReader 不支持 Seek 的 reader
+type onceReader struct {
+	io.Reader
+}
+
+func TestStreamUpload(t *testing.T) {
+	type upload struct {
+		length  int64
+		chunked bool
+		body    string
+	}
+	var (
+		mu      sync.Mutex
+		uploads []upload
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		uploads = append(uploads, upload{r.ContentLength, len(r.TransferEncoding) > 0 && r.TransferEncoding[0] == "chunked", string(body)})
+		mu.Unlock()
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	seeker := strings.NewReader("xxpayload")
+	seeker.Seek(2, io.SeekStart)
+	tests := []struct {
+		name    string
+		body    io.Reader
+		size    int64
+		uploads []upload
+	}{
+		{"known length", onceReader{strings.NewReader("payload")}, 7, []upload{{7, false, "payload"}}},
+		{"chunked", onceReader{strings.NewReader("payload")}, -1, []upload{{-1, true, "payload"}}},
+		// 可 Seek 时每次重试从调用 SetBodyStream 时的位置重新发送
+		{"seekable retried", seeker, 7, []upload{{7, false, "payload"}, {7, false, "payload"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			uploads = nil
+			var progress []int64
+			code, err := New().PUT(srv.URL).SetBodyStream(tt.body, tt.size).
+				UploadProgress(func(current, total int64) {
+					progress = append(progress, current, total)
+				}).
+				Retry(&RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond}).Do()
+			if err != nil || code != http.StatusServiceUnavailable {
+				t.Fatal(code, err)
+			}
+			if !reflect.DeepEqual(uploads, tt.uploads) {
+				t.Fatalf("expected %+v, got %+v", tt.uploads, uploads)
+			}
+			total := tt.size
+			if total < 0 {
+				total = -1
+			}
+			if len(progress) < 2 || progress[len(progress)-2] != 7 || progress[len(progress)-1] != total {
+				t.Fatalf("unexpected upload progress %v", progress)
+			}
+		})
+	}
+}
+
+func TestStreamUploadKeepsError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("expected request not to be sent")
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name string
+		flow func() *DataFlow
+		err  string
+	}{
+		{"client error", func() *DataFlow {
+			return NewClient(WithTransportConfig(&TransportConfig{Proxy: "ftp://proxy.local"})).PUT(srv.URL)
+		}, "unsupported proxy scheme"},
+		{"body error", func() *DataFlow {
+			return New().PUT(srv.URL).SetBodyWith("application/x-unknown", nil)
+		}, "codec"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// 可 Seek 的 body 不会清除之前记录的错误
+			_, err := tt.flow().SetBodyStream(strings.NewReader("payload"), 7).Do()
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("expected error containing %q, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestStream(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "10")
+		io.WriteString(w, "0123456789")
+	}))
+	defer srv.Close()
+
+	var progress []int64
+	code, body, err := New().GET(srv.URL).DownloadProgress(func(current, total int64) {
+		progress = append(progress, current, total)
+	}).Stream()
+	if err != nil || code != http.StatusOK {
+		t.Fatal(code, err)
+	}
+	data, _ := io.ReadAll(body)
+	if err = body.Close(); err != nil || string(data) != "0123456789" {
+		t.Fatalf("unexpected body %q %v", data, err)
+	}
+	if len(progress) < 2 || progress[len(progress)-2] != 10 || progress[len(progress)-1] != 10 {
+		t.Fatalf("unexpected download progress %v", progress)
+	}
+
+	if _, _, err = New().GET(srv.URL).ExpectStatus(http.StatusCreated).Stream(); err == nil {
+		t.Fatal("expected status error")
+	}
+}
+
+func TestClientConcurrent(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%s %s %s", r.URL.Path, r.Header.Get("X-App"), r.URL.Query().Get("i"))
+	}))
+	defer srv.Close()
+
+	client := NewClient(WithBaseURL(srv.URL+"/api/"), WithHeader("X-App", "demo"), WithTimeout(time.Second))
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			resp, err := client.GET("/users").AddQuery("i", fmt.Sprint(i)).DoResponse()
+			if err != nil {
+				errs <- err
+				return
+			}
+			if want := fmt.Sprintf("/api/users demo %d", i); string(resp.Body) != want {
+				errs <- fmt.Errorf("expected %q, got %q", want, resp.Body)
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
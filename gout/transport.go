@@ -0,0 +1,292 @@
+package gout
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+)
+
+// TransportConfig 连接相关配置，可通过 config.Config.Unmarshal 加载，时长支持 "5s" 格式
+type TransportConfig struct {
+	// Proxy 代理地址，支持 http、https、socks5 与 socks5h，可带用户名密码；为空时读取 HTTP_PROXY/HTTPS_PROXY/NO_PROXY 环境变量
+	Proxy string `config:"proxy" json:"proxy" yaml:"proxy"`
+	// NoProxy 为 true 时不使用任何代理，包括环境变量
+	NoProxy bool `config:"no_proxy" json:"no_proxy" yaml:"no_proxy"`
+
+	// CAFile/CAPEM 信任的 CA 证书，追加在系统证书之后
+	CAFile string `config:"ca_file" json:"ca_file" yaml:"ca_file"`
+	CAPEM  string `config:"ca_pem" json:"ca_pem" yaml:"ca_pem"`
+	// CertFile/KeyFile 或 CertPEM/KeyPEM mTLS 客户端证书，PEM 内容可直接使用 common/x509cert.GenerateClient 生成的结果
+	CertFile string `config:"cert_file" json:"cert_file" yaml:"cert_file"`
+	KeyFile  string `config:"key_file" json:"key_file" yaml:"key_file"`
+	CertPEM  string `config:"cert_pem" json:"cert_pem" yaml:"cert_pem"`
+	KeyPEM   string `config:"key_pem" json:"key_pem" yaml:"key_pem"`
+	// ServerName 校验服务端证书时使用的名称，默认取请求地址的 host
+	ServerName         string `config:"server_name" json:"server_name" yaml:"server_name"`
+	InsecureSkipVerify bool   `config:"insecure_skip_verify" json:"insecure_skip_verify" yaml:"insecure_skip_verify"`
+
+	MaxIdleConns        int           `config:"max_idle_conns" json:"max_idle_conns" yaml:"max_idle_conns"`                            // 默认 100
+	MaxIdleConnsPerHost int           `config:"max_idle_conns_per_host" json:"max_idle_conns_per_host" yaml:"max_idle_conns_per_host"` // 默认 10
+	MaxConnsPerHost     int           `config:"max_conns_per_host" json:"max_conns_per_host" yaml:"max_conns_per_host"`                // 0 不限制
+	IdleConnTimeout     time.Duration `config:"idle_conn_timeout" json:"idle_conn_timeout" yaml:"idle_conn_timeout"`                   // 默认 90s
+
+	// DisableHTTP2 关闭 HTTP/2，只使用 HTTP/1.1
+	DisableHTTP2 bool `config:"disable_http2" json:"disable_http2" yaml:"disable_http2"`
+
+	DialTimeout           time.Duration `config:"dial_timeout" json:"dial_timeout" yaml:"dial_timeout"`                                  // 默认 30s
+	KeepAlive             time.Duration `config:"keep_alive" json:"keep_alive" yaml:"keep_alive"`                                        // 默认 30s
+	TLSHandshakeTimeout   time.Duration `config:"tls_handshake_timeout" json:"tls_handshake_timeout" yaml:"tls_handshake_timeout"`       // 默认 10s
+	ResponseHeaderTimeout time.Duration `config:"response_header_timeout" json:"response_header_timeout" yaml:"response_header_timeout"` // 0 不限制
+	// HostDialTimeouts 按 host 或 host:port 单独设置建连超时，优先匹配 host:port
+	HostDialTimeouts map[string]time.Duration `config:"host_dial_timeouts" json:"host_dial_timeouts" yaml:"host_dial_timeouts"`
+
+	// DNSCacheTTL 大于 0 时缓存域名解析结果，建连失败时清除对应缓存
+	DNSCacheTTL time.Duration `config:"dns_cache_ttl" json:"dns_cache_ttl" yaml:"dns_cache_ttl"`
+}
+
+// DefaultTransportConfig 默认配置，与 http.DefaultTransport 一致，每个 host 的空闲连接数调整为 10
+func DefaultTransportConfig() *TransportConfig {
+	return &TransportConfig{
+		MaxIdleConns:        100,
+		MaxIdleConnsPerHost: 10,
+		IdleConnTimeout:     90 * time.Second,
+		DialTimeout:         30 * time.Second,
+		KeepAlive:           30 * time.Second,
+		TLSHandshakeTimeout: 10 * time.Second,
+	}
+}
+
+func (cfg *TransportConfig) withDefaults() *TransportConfig {
+	def := DefaultTransportConfig()
+	if cfg == nil {
+		return def
+	}
+	c := *cfg
+	if c.MaxIdleConns <= 0 {
+		c.MaxIdleConns = def.MaxIdleConns
+	}
+	if c.MaxIdleConnsPerHost <= 0 {
+		c.MaxIdleConnsPerHost = def.MaxIdleConnsPerHost
+	}
+	if c.IdleConnTimeout <= 0 {
+		c.IdleConnTimeout = def.IdleConnTimeout
+	}
+	if c.DialTimeout <= 0 {
+		c.DialTimeout = def.DialTimeout
+	}
+	if c.KeepAlive <= 0 {
+		c.KeepAlive = def.KeepAlive
+	}
+	if c.TLSHandshakeTimeout <= 0 {
+		c.TLSHandshakeTimeout = def.TLSHandshakeTimeout
+	}
+	return &c
+}
+
+// NewTransport 按配置创建 http.Transport，未设置的字段使用 DefaultTransportConfig 中的值
+func NewTransport(cfg *TransportConfig) (*http.Transport, error) {
+	cfg = cfg.withDefaults()
+
+	proxy, err := cfg.proxy()
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig, err := cfg.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	d := &dialer{
+		dialer:   net.Dialer{KeepAlive: cfg.KeepAlive},
+		timeout:  cfg.DialTimeout,
+		timeouts: cfg.HostDialTimeouts,
+	}
+	if cfg.DNSCacheTTL > 0 {
+		d.dns = &dnsCache{ttl: cfg.DNSCacheTTL, resolver: net.DefaultResolver, entries: map[string]*dnsEntry{}}
+	}
+
+	protocols := new(http.Protocols)
+	protocols.SetHTTP1(true)
+	protocols.SetHTTP2(!cfg.DisableHTTP2)
+
+	return &http.Transport{
+		Proxy:                 proxy,
+		DialContext:           d.DialContext,
+		TLSClientConfig:       tlsConfig,
+		TLSHandshakeTimeout:   cfg.TLSHandshakeTimeout,
+		MaxIdleConns:          cfg.MaxIdleConns,
+		MaxIdleConnsPerHost:   cfg.MaxIdleConnsPerHost,
+		MaxConnsPerHost:       cfg.MaxConnsPerHost,
+		IdleConnTimeout:       cfg.IdleConnTimeout,
+		ResponseHeaderTimeout: cfg.ResponseHeaderTimeout,
+		ExpectContinueTimeout: time.Second,
+		Protocols:             protocols,
+	}, nil
+}
+
+// WithTransportConfig 按配置创建底层 Transport，配置有误时该 Client 发出的请求都会返回该错误
+func WithTransportConfig(cfg *TransportConfig) Option {
+	return func(c *Client) {
+		transport, err := NewTransport(cfg)
+		if err != nil {
+			c.err = err
+			return
+		}
+		c.transport = transport
+	}
+}
+
+func (cfg *TransportConfig) proxy() (func(*http.Request) (*url.URL, error), error) {
+	switch {
+	case cfg.NoProxy:
+		return nil, nil
+	case cfg.Proxy == "":
+		return http.ProxyFromEnvironment, nil
+	}
+
+	u, err := url.Parse(cfg.Proxy)
+	if err != nil {
+		return nil, fmt.Errorf("gout: invalid proxy %q: %w", cfg.Proxy, err)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("gout: unsupported proxy scheme %q", u.Scheme)
+	}
+	return http.ProxyURL(u), nil
+}
+
+func (cfg *TransportConfig) tlsConfig() (*tls.Config, error) {
+	c := &tls.Config{
+		ServerName:         cfg.ServerName,
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+	}
+
+	if cfg.CAFile != "" || cfg.CAPEM != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		ca := []byte(cfg.CAPEM)
+		if cfg.CAFile != "" {
+			if ca, err = os.ReadFile(cfg.CAFile); err != nil {
+				return nil, fmt.Errorf("gout: read ca file: %w", err)
+			}
+		}
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, errors.New("gout: no valid ca certificate found")
+		}
+		c.RootCAs = pool
+	}
+
+	var (
+		cert tls.Certificate
+		err  error
+	)
+	switch {
+	case cfg.CertFile != "" || cfg.KeyFile != "":
+		cert, err = tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+	case cfg.CertPEM != "" || cfg.KeyPEM != "":
+		cert, err = tls.X509KeyPair([]byte(cfg.CertPEM), []byte(cfg.KeyPEM))
+	default:
+		return c, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("gout: load client certificate: %w", err)
+	}
+	c.Certificates = []tls.Certificate{cert}
+	return c, nil
+}
+
+type dialer struct {
+	dialer   net.Dialer
+	timeout  time.Duration
+	timeouts map[string]time.Duration
+	dns      *dnsCache
+}
+
+func (d *dialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	timeout, ok := d.timeouts[addr]
+	if !ok {
+		if timeout, ok = d.timeouts[host]; !ok {
+			timeout = d.timeout
+		}
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	if d.dns == nil || net.ParseIP(host) != nil {
+		return d.dialer.DialContext(ctx, network, addr)
+	}
+
+	ips, err := d.dns.lookup(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	var firstErr error
+	for _, ip := range ips {
+		conn, err := d.dialer.DialContext(ctx, network, net.JoinHostPort(ip, port))
+		if err == nil {
+			return conn, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	d.dns.remove(host)
+	return nil, firstErr
+}
+
+type dnsEntry struct {
+	ips     []string
+	expires time.Time
+}
+
+// dnsCache 缓存域名解析结果，过期后重新解析
+type dnsCache struct {
+	ttl      time.Duration
+	resolver *net.Resolver
+
+	mu      sync.Mutex
+	entries map[string]*dnsEntry
+}
+
+func (c *dnsCache) lookup(ctx context.Context, host string) ([]string, error) {
+	c.mu.Lock()
+	entry, ok := c.entries[host]
+	c.mu.Unlock()
+	if ok && time.Now().Before(entry.expires) {
+		return entry.ips, nil
+	}
+
+	ips, err := c.resolver.LookupHost(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	c.entries[host] = &dnsEntry{ips: ips, expires: time.Now().Add(c.ttl)}
+	c.mu.Unlock()
+	return ips, nil
+}
+
+func (c *dnsCache) remove(host string) {
+	c.mu.Lock()
+	delete(c.entries, host)
+	c.mu.Unlock()
+}
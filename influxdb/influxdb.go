@@ -41,6 +41,18 @@ func (cli *Client) Close() {
 	cli.cli.Close()
 }
 
+// Ping 检查 influxdb 服务是否可用
+func (cli *Client) Ping(ctx context.Context) error {
+	ok, err := cli.cli.Ping(ctx)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("influxdb ping failed: %s", cli.cli.ServerURL())
+	}
+	return nil
+}
+
 func (cli *Client) Client() influxdb2.Client {
 	return cli.cli
 }
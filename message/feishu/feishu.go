@@ -70,6 +70,7 @@ func (client *Client) SendWebhookMessage(url string, subject, content string, at
 	}
 	_, err := gout.POST(url).
 		Debug().
+		Retry(nil).
 		SetJSON(reqBody).Do()
 	if err != nil {
 		return err
@@ -36,6 +36,7 @@ func (c *Client) GetAccessToken() error {
 	if _, err := gout.GET("https://qyapi.weixin.qq.com/cgi-bin/gettoken").
 		AddQuery("corpid", c.corpid).
 		AddQuery("corpsecret", c.corpsecret).
+		Retry(nil).
 		BindJSON(&result).
 		Do(); err != nil {
 		return fmt.Errorf("failed to send request: %v", err)
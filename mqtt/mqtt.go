@@ -1,6 +1,9 @@
 package mqtt
 
 import (
+	"context"
+	"errors"
+
 	mqtt "github.com/eclipse/paho.mqtt.golang"
 )
 
@@ -73,6 +76,14 @@ func (c *Client) Close() {
 	c.cli.Disconnect(250)
 }
 
+// Ping 检查与 broker 的连接是否可用
+func (c *Client) Ping(ctx context.Context) error {
+	if c.cli == nil || !c.cli.IsConnectionOpen() {
+		return errors.New("mqtt connection is not open")
+	}
+	return ctx.Err()
+}
+
 func NewClientOptions(opts ...Option) *mqtt.ClientOptions {
 	var opt option
 	for _, o := range opts {
@@ -0,0 +1,159 @@
+package prometheus
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+// 构建信息，通过 ldflags 注入，例如：
+//
+//	go build -ldflags "-X github.com/ffhuo/go-kits/prometheus.Version=v1.2.0 \
+//	  -X github.com/ffhuo/go-kits/prometheus.Commit=$(git rev-parse HEAD) \
+//	  -X github.com/ffhuo/go-kits/prometheus.BuildTime=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+//
+// 未注入时从 runtime/debug.ReadBuildInfo 读取模块版本及 vcs 信息
+var (
+	Version   string
+	Commit    string
+	BuildTime string
+)
+
+// GoCollector 注册 Go 运行时指标（go_goroutines、go_memstats_* 等）
+func GoCollector() Config {
+	return func(p *Prometheus) {
+		p.collectors = append(p.collectors, collectors.NewGoCollector())
+	}
+}
+
+// ProcessCollector 注册进程指标（process_cpu_seconds_total、process_open_fds 等）
+func ProcessCollector() Config {
+	return func(p *Prometheus) {
+		p.collectors = append(p.collectors, collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+	}
+}
+
+// BuildInfo 注册 build_info 指标，值恒为 1，label 为 version、commit、build_time、go_version
+func BuildInfo() Config {
+	return func(p *Prometheus) {
+		version, commit, buildTime := buildInfo()
+		gauge := prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "build_info",
+			Help: "A metric with a constant '1' value labeled by version, commit and build time of the binary.",
+			ConstLabels: prometheus.Labels{
+				"version":    version,
+				"commit":     commit,
+				"build_time": buildTime,
+				"go_version": runtime.Version(),
+			},
+		})
+		gauge.Set(1)
+		p.collectors = append(p.collectors, gauge)
+	}
+}
+
+func buildInfo() (version, commit, buildTime string) {
+	version, commit, buildTime = Version, Commit, BuildTime
+	if info, ok := debug.ReadBuildInfo(); ok {
+		if version == "" {
+			version = info.Main.Version
+		}
+		for _, s := range info.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				if commit == "" {
+					commit = s.Value
+				}
+			case "vcs.time":
+				if buildTime == "" {
+					buildTime = s.Value
+				}
+			}
+		}
+	}
+	if version == "" {
+		version = "unknown"
+	}
+	return version, commit, buildTime
+}
+
+// RegisterCounter 创建并注册 CounterVec，可通过 Counter(name) 获取，name 为带 namespace、subsystem 的完整名称。
+// opts 未设置 ConstLabels 时使用 Init 设置的 ConstLabels，同名指标已注册时返回已注册的指标
+func (gp *Prometheus) RegisterCounter(opts prometheus.CounterOpts, labels ...string) (*prometheus.CounterVec, error) {
+	if opts.ConstLabels == nil {
+		opts.ConstLabels = gp.constLabels
+	}
+	name := prometheus.BuildFQName(opts.Namespace, opts.Subsystem, opts.Name)
+	return registerNamed(gp, name, func() *prometheus.CounterVec {
+		return prometheus.NewCounterVec(opts, labels)
+	})
+}
+
+// RegisterGauge 创建并注册 GaugeVec，可通过 Gauge(name) 获取
+func (gp *Prometheus) RegisterGauge(opts prometheus.GaugeOpts, labels ...string) (*prometheus.GaugeVec, error) {
+	if opts.ConstLabels == nil {
+		opts.ConstLabels = gp.constLabels
+	}
+	name := prometheus.BuildFQName(opts.Namespace, opts.Subsystem, opts.Name)
+	return registerNamed(gp, name, func() *prometheus.GaugeVec {
+		return prometheus.NewGaugeVec(opts, labels)
+	})
+}
+
+// RegisterHistogram 创建并注册 HistogramVec，可通过 Histogram(name) 获取
+func (gp *Prometheus) RegisterHistogram(opts prometheus.HistogramOpts, labels ...string) (*prometheus.HistogramVec, error) {
+	if opts.ConstLabels == nil {
+		opts.ConstLabels = gp.constLabels
+	}
+	name := prometheus.BuildFQName(opts.Namespace, opts.Subsystem, opts.Name)
+	return registerNamed(gp, name, func() *prometheus.HistogramVec {
+		return prometheus.NewHistogramVec(opts, labels)
+	})
+}
+
+// Counter 获取 RegisterCounter 注册的指标
+func (gp *Prometheus) Counter(name string) (*prometheus.CounterVec, bool) {
+	return LoadCollectorAs[*prometheus.CounterVec](gp, name)
+}
+
+// Gauge 获取 RegisterGauge 注册的指标
+func (gp *Prometheus) Gauge(name string) (*prometheus.GaugeVec, bool) {
+	return LoadCollectorAs[*prometheus.GaugeVec](gp, name)
+}
+
+// Histogram 获取 RegisterHistogram 注册的指标
+func (gp *Prometheus) Histogram(name string) (*prometheus.HistogramVec, bool) {
+	return LoadCollectorAs[*prometheus.HistogramVec](gp, name)
+}
+
+// LoadCollectorAs 按类型获取 RegisterCollector 注册的指标，不存在或类型不符时返回 false
+func LoadCollectorAs[T prometheus.Collector](gp *Prometheus, name string) (T, bool) {
+	v, ok := gp.cs.Load(name)
+	if !ok {
+		var zero T
+		return zero, false
+	}
+	c, ok := v.(T)
+	return c, ok
+}
+
+// registerNamed 按名称注册指标，名称已存在且类型一致时返回已存在的指标
+func registerNamed[T prometheus.Collector](gp *Prometheus, name string, newCollector func() T) (T, error) {
+	if existing, ok := gp.cs.Load(name); ok {
+		if c, ok := existing.(T); ok {
+			return c, nil
+		}
+		var zero T
+		return zero, fmt.Errorf("collector %s already registered with type %T", name, existing)
+	}
+
+	c, err := Register(gp.registerer, newCollector())
+	if err != nil {
+		return c, err
+	}
+	actual, _ := gp.cs.LoadOrStore(name, c)
+	return actual.(T), nil
+}
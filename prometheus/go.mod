@@ -2,7 +2,10 @@ module github.com/ffhuo/go-kits/prometheus
 
 go 1.24.3
 
+replace github.com/ffhuo/go-kits => ../
+
 require (
+	github.com/ffhuo/go-kits v0.0.0-00010101000000-000000000000
 	github.com/gin-gonic/gin v1.10.0
 	github.com/prometheus/client_golang v1.21.1
 	go.uber.org/zap v1.27.0
@@ -25,6 +28,7 @@ require (
 	github.com/json-iterator/go v1.1.12 // indirect
 	github.com/klauspost/compress v1.17.11 // indirect
 	github.com/klauspost/cpuid/v2 v2.2.9 // indirect
+	github.com/kylelemons/godebug v1.1.0 // indirect
 	github.com/leodido/go-urn v1.4.0 // indirect
 	github.com/mattn/go-isatty v0.0.20 // indirect
 	github.com/modern-go/concurrent v0.0.0-20180306012644-bacd9c7ef1dd // indirect
@@ -1,27 +1,46 @@
 package prometheus
 
 import (
+	"context"
 	"errors"
+	"net/http"
 	"strconv"
 	"sync"
 	"time"
 
+	"github.com/ffhuo/go-kits/common/metrics"
 	"github.com/gin-gonic/gin"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
-	"github.com/prometheus/client_golang/prometheus/push"
 	"go.uber.org/zap"
 )
 
 var (
-	RefreshInterval     = 15 * time.Second
-	defaultHttpHistoram = "requests_cost"
-	defaultPanicCounter = "panic_total"
+	RefreshInterval       = 15 * time.Second
+	defaultNamespace      = "http_server"
+	defaultHttpHistoram   = "requests_cost"
+	defaultInFlightGauge  = "requests_in_flight"
+	defaultRequestSize    = "request_size_bytes"
+	defaultResponseSize   = "response_size_bytes"
+	defaultPanicCounter   = "panic_total"
+	defaultSizeBuckets    = prometheus.ExponentialBuckets(64, 4, 8) // 64B ~ 1MB
+	unmatchedRouteLabel   = "unmatched"
+	httpMetricsLabelNames = []string{"method", "code", "uri"}
 )
 
 type Prometheus struct {
 	cs  sync.Map
 	log *zap.Logger
+
+	registerer prometheus.Registerer
+	gatherer   prometheus.Gatherer
+	engine     *gin.Engine
+	collectors []prometheus.Collector // GoCollector、ProcessCollector、BuildInfo 等附加指标
+
+	namespace   string
+	buckets     []float64
+	sizeBuckets []float64
+	constLabels prometheus.Labels
 }
 
 type Config func(*Prometheus)
@@ -32,43 +51,144 @@ func Logger(log *zap.Logger) Config {
 	}
 }
 
+// Handler 在 engine 上注册 /metrics，输出 Registry 设置的注册表中的指标
 func Handler(engine *gin.Engine) Config {
 	return func(p *Prometheus) {
-		engine.GET("/metrics", gin.WrapH(promhttp.Handler()))
+		p.engine = engine
+	}
+}
+
+// Registry 设置指标注册表，默认 prometheus.DefaultRegisterer。
+// reg 同时实现 prometheus.Gatherer（如 *prometheus.Registry）时 /metrics 及 Push 输出其中的指标
+func Registry(reg prometheus.Registerer) Config {
+	return func(p *Prometheus) {
+		p.registerer = reg
+		if g, ok := reg.(prometheus.Gatherer); ok {
+			p.gatherer = g
+		}
+	}
+}
+
+// Namespace 设置 http 指标的 namespace，默认 http_server
+func Namespace(namespace string) Config {
+	return func(p *Prometheus) {
+		p.namespace = namespace
+	}
+}
+
+// Buckets 设置请求耗时（秒）的 bucket，默认 prometheus.DefBuckets
+func Buckets(buckets []float64) Config {
+	return func(p *Prometheus) {
+		p.buckets = buckets
+	}
+}
+
+// SizeBuckets 设置请求、响应大小（字节）的 bucket，默认 64B ~ 1MB 按 4 倍递增
+func SizeBuckets(buckets []float64) Config {
+	return func(p *Prometheus) {
+		p.sizeBuckets = buckets
+	}
+}
+
+// ConstLabels 设置所有指标的固定 label，例如 service、env
+func ConstLabels(labels prometheus.Labels) Config {
+	return func(p *Prometheus) {
+		p.constLabels = labels
 	}
 }
 
 // New new gin prometheus
 func Init(configs ...Config) *Prometheus {
+	gp := &Prometheus{
+		namespace:   defaultNamespace,
+		sizeBuckets: defaultSizeBuckets,
+		registerer:  prometheus.DefaultRegisterer,
+		gatherer:    prometheus.DefaultGatherer,
+	}
+	for _, conf := range configs {
+		conf(gp)
+	}
+
 	var (
 		// httpHistogram prometheus 模型
 		httpHistogram = prometheus.NewHistogramVec(prometheus.HistogramOpts{
-			Namespace:   "http_server",
-			Subsystem:   "",
+			Namespace:   gp.namespace,
 			Name:        defaultHttpHistoram,
 			Help:        "Histogram of response latency (seconds) of http handlers.",
-			ConstLabels: nil,
-			Buckets:     nil,
-		}, []string{"method", "code", "uri"})
+			ConstLabels: gp.constLabels,
+			Buckets:     gp.buckets,
+		}, httpMetricsLabelNames)
+
+		inFlightGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   gp.namespace,
+			Name:        defaultInFlightGauge,
+			Help:        "The number of http requests currently being served.",
+			ConstLabels: gp.constLabels,
+		}, []string{"method", "uri"})
+
+		requestSize = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace:   gp.namespace,
+			Name:        defaultRequestSize,
+			Help:        "Histogram of http request body size (bytes).",
+			ConstLabels: gp.constLabels,
+			Buckets:     gp.sizeBuckets,
+		}, httpMetricsLabelNames)
+
+		responseSize = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace:   gp.namespace,
+			Name:        defaultResponseSize,
+			Help:        "Histogram of http response body size (bytes).",
+			ConstLabels: gp.constLabels,
+			Buckets:     gp.sizeBuckets,
+		}, httpMetricsLabelNames)
 
 		panicCounter = prometheus.NewCounter(prometheus.CounterOpts{
-			Name: defaultPanicCounter,
-			Help: "The total number of server panic.",
+			Name:        defaultPanicCounter,
+			Help:        "The total number of server panic.",
+			ConstLabels: gp.constLabels,
 		})
 	)
 
-	gp := &Prometheus{}
-	for _, conf := range configs {
-		conf(gp)
-	}
+	// 同一注册表重复 Init 时复用已注册的指标
+	gp.cs.Store(defaultHttpHistoram, MustRegister(gp.registerer, httpHistogram))
+	gp.cs.Store(defaultInFlightGauge, MustRegister(gp.registerer, inFlightGauge))
+	gp.cs.Store(defaultRequestSize, MustRegister(gp.registerer, requestSize))
+	gp.cs.Store(defaultResponseSize, MustRegister(gp.registerer, responseSize))
+	gp.cs.Store(defaultPanicCounter, MustRegister(gp.registerer, panicCounter))
 
-	gp.cs.Store(defaultHttpHistoram, httpHistogram)
-	gp.cs.Store(defaultPanicCounter, panicCounter)
+	for _, c := range gp.collectors {
+		MustRegister(gp.registerer, c)
+	}
 
-	prometheus.MustRegister(httpHistogram, panicCounter)
+	if gp.engine != nil {
+		gp.engine.GET("/metrics", gin.WrapH(gp.HTTPHandler()))
+	}
 	return gp
 }
 
+// Register 将 c 注册到 reg，已注册过相同的指标时返回已存在的指标，同 common/metrics.Register
+func Register[T prometheus.Collector](reg prometheus.Registerer, c T) (T, error) {
+	return metrics.Register(reg, c)
+}
+
+// MustRegister 同 Register，注册失败（如同名指标的 label 不一致）时 panic
+func MustRegister[T prometheus.Collector](reg prometheus.Registerer, c T) T {
+	return metrics.MustRegister(reg, c)
+}
+
+// HTTPHandler 输出注册表中指标的 http.Handler
+func (gp *Prometheus) HTTPHandler() http.Handler {
+	if gp.gatherer == prometheus.DefaultGatherer {
+		return promhttp.Handler()
+	}
+	return promhttp.HandlerFor(gp.gatherer, promhttp.HandlerOpts{})
+}
+
+// Registerer 指标注册表
+func (gp *Prometheus) Registerer() prometheus.Registerer {
+	return gp.registerer
+}
+
 func (gp *Prometheus) PanicInc() {
 	panicCounter, ok := gp.cs.Load(defaultPanicCounter)
 	if !ok {
@@ -77,23 +197,25 @@ func (gp *Prometheus) PanicInc() {
 	panicCounter.(prometheus.Counter).Inc()
 }
 
+// Push 每隔 RefreshInterval 推送一次指标，不会返回
+//
+// Deprecated: 使用 NewPusher 创建 Pusher，通过 Run(ctx) 控制推送的生命周期
 func (gp *Prometheus) Push(addr, job string) {
-	pusher := push.New(addr, job)
-
-	for range time.Tick(RefreshInterval) {
-		err := pusher.Push()
-		if err != nil && gp.log != nil {
-			gp.log.Error("prometheus push err:", zap.Error(err))
-		}
-	}
+	_ = gp.NewPusher(&PushConfig{URL: addr, Job: job}).Run(context.Background())
 }
 
+// RegisterCollector 将指标注册到注册表并按名称保存，可通过 LoadCollector 获取，
+// 注册表中已有相同的指标时保存已存在的指标
 func (gp *Prometheus) RegisterCollector(name string, cs prometheus.Collector) error {
-	_, ok := gp.cs.Load(name)
-	if ok {
+	if _, loaded := gp.cs.LoadOrStore(name, cs); loaded {
 		return errors.New("name exist")
 	}
-	gp.cs.Store(name, cs)
+	existing, err := Register(gp.registerer, cs)
+	if err != nil {
+		gp.cs.Delete(name)
+		return err
+	}
+	gp.cs.Store(name, existing)
 	return nil
 }
 
@@ -105,28 +227,52 @@ func (gp *Prometheus) LoadCollector(name string) (interface{}, bool) {
 	return v, true
 }
 
-// Middleware set gin middleware
+// Middleware set gin middleware，uri label 为路由模式（如 /users/:id），未匹配路由时为 unmatched，
+// ignored 中可以是请求路径或路由模式
 func (gp *Prometheus) Middleware(ignored map[string]bool) gin.HandlerFunc {
+	httpHistogram, _ := gp.cs.Load(defaultHttpHistoram)
+	inFlightGauge, _ := gp.cs.Load(defaultInFlightGauge)
+	requestSize, _ := gp.cs.Load(defaultRequestSize)
+	responseSize, _ := gp.cs.Load(defaultResponseSize)
+
 	return func(c *gin.Context) {
 		// 过滤请求
-		if ignored[c.Request.URL.String()] {
+		uri := c.FullPath()
+		if ignored[c.Request.URL.Path] || (uri != "" && ignored[uri]) {
 			c.Next()
 			return
 		}
+		if uri == "" {
+			uri = unmatchedRouteLabel
+		}
+		method := c.Request.Method
+
+		if g, ok := inFlightGauge.(*prometheus.GaugeVec); ok {
+			inFlight := g.WithLabelValues(method, uri)
+			inFlight.Inc()
+			defer inFlight.Dec()
+		}
 
 		start := time.Now()
 		c.Next()
 
-		httpHistogram, ok := gp.cs.Load(defaultHttpHistoram)
-		if !ok {
-			c.Next()
-			return
+		code := strconv.Itoa(c.Writer.Status())
+		if h, ok := httpHistogram.(*prometheus.HistogramVec); ok {
+			h.WithLabelValues(method, code, uri).Observe(time.Since(start).Seconds())
+		}
+		if h, ok := requestSize.(*prometheus.HistogramVec); ok {
+			size := c.Request.ContentLength
+			if size < 0 {
+				size = 0
+			}
+			h.WithLabelValues(method, code, uri).Observe(float64(size))
+		}
+		if h, ok := responseSize.(*prometheus.HistogramVec); ok {
+			size := c.Writer.Size()
+			if size < 0 {
+				size = 0
+			}
+			h.WithLabelValues(method, code, uri).Observe(float64(size))
 		}
-
-		(httpHistogram.(*prometheus.HistogramVec)).WithLabelValues(
-			c.Request.Method,
-			strconv.Itoa(c.Writer.Status()),
-			c.Request.URL.Path,
-		).Observe(time.Since(start).Seconds())
 	}
 }
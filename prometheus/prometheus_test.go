@@ -0,0 +1,83 @@
+package prometheus
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestInitTwice(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	first := Init(Registry(registry), GoCollector(), ProcessCollector(), BuildInfo())
+	second := Init(Registry(registry), GoCollector(), ProcessCollector(), BuildInfo())
+
+	first.PanicInc()
+	second.PanicInc()
+
+	counter, ok := LoadCollectorAs[prometheus.Counter](second, defaultPanicCounter)
+	if !ok {
+		t.Fatal("Expected panic counter to be loaded")
+	}
+	if v := testutil.ToFloat64(counter); v != 2 {
+		t.Errorf("Expected both instances to share the panic counter, got %v", v)
+	}
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("Gather failed: %v", err)
+	}
+	names := make([]string, 0, len(families))
+	for _, f := range families {
+		names = append(names, f.GetName())
+	}
+	for _, name := range []string{"build_info", "go_goroutines", "panic_total"} {
+		if !strings.Contains(strings.Join(names, ","), name) {
+			t.Errorf("Expected %s to be registered, got %v", name, names)
+		}
+	}
+}
+
+func TestTypedCollectors(t *testing.T) {
+	gp := Init(Registry(prometheus.NewRegistry()), ConstLabels(prometheus.Labels{"service": "order"}))
+
+	counter, err := gp.RegisterCounter(prometheus.CounterOpts{
+		Namespace: "shop",
+		Name:      "orders_total",
+		Help:      "The total number of orders.",
+	}, "status")
+	if err != nil {
+		t.Fatalf("RegisterCounter failed: %v", err)
+	}
+	counter.WithLabelValues("paid").Inc()
+
+	again, err := gp.RegisterCounter(prometheus.CounterOpts{
+		Namespace: "shop",
+		Name:      "orders_total",
+		Help:      "The total number of orders.",
+	}, "status")
+	if err != nil || again != counter {
+		t.Errorf("Expected duplicate registration to return the existing counter, got %v", err)
+	}
+
+	loaded, ok := gp.Counter("shop_orders_total")
+	if !ok || testutil.ToFloat64(loaded.WithLabelValues("paid")) != 1 {
+		t.Error("Expected Counter to return the registered counter")
+	}
+	if _, ok := gp.Gauge("shop_orders_total"); ok {
+		t.Error("Expected Gauge to reject a counter")
+	}
+	if _, err := gp.RegisterGauge(prometheus.GaugeOpts{Namespace: "shop", Name: "orders_total", Help: "gauge"}); err == nil {
+		t.Error("Expected registering a gauge with a counter's name to fail")
+	}
+
+	histogram, err := gp.RegisterHistogram(prometheus.HistogramOpts{Name: "order_amount", Help: "Order amount."})
+	if err != nil {
+		t.Fatalf("RegisterHistogram failed: %v", err)
+	}
+	histogram.WithLabelValues().Observe(10)
+	if n := testutil.CollectAndCount(histogram); n != 1 {
+		t.Errorf("Expected 1 histogram series, got %d", n)
+	}
+}
@@ -0,0 +1,119 @@
+package prometheus
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/push"
+	"go.uber.org/zap"
+)
+
+// finalPushTimeout 停止时最后一次推送的超时时间
+var finalPushTimeout = 5 * time.Second
+
+// PushConfig Pushgateway 推送配置
+type PushConfig struct {
+	URL      string              // Pushgateway 地址
+	Job      string              // job 名称
+	Gatherer prometheus.Gatherer // 推送的指标，默认 prometheus.DefaultGatherer
+	Grouping map[string]string   // 分组 label，例如 instance
+	Username string              // basic auth 用户名
+	Password string              // basic auth 密码
+	Interval time.Duration       // 推送间隔，默认 RefreshInterval
+	Add      bool                // true 使用 POST 只替换同名指标，false 使用 PUT 替换分组内所有指标
+	Client   *http.Client        // 自定义 http client，默认 http.DefaultClient
+	OnError  func(err error)     // 推送失败回调，为空时记录日志
+}
+
+// Pusher 定时将指标推送到 Pushgateway
+type Pusher struct {
+	pusher   *push.Pusher
+	interval time.Duration
+	add      bool
+	onError  func(err error)
+}
+
+// NewPusher 创建 Pusher
+func NewPusher(cfg *PushConfig) *Pusher {
+	gatherer := cfg.Gatherer
+	if gatherer == nil {
+		gatherer = prometheus.DefaultGatherer
+	}
+
+	pusher := push.New(cfg.URL, cfg.Job).Gatherer(gatherer)
+	for name, value := range cfg.Grouping {
+		pusher = pusher.Grouping(name, value)
+	}
+	if cfg.Username != "" || cfg.Password != "" {
+		pusher = pusher.BasicAuth(cfg.Username, cfg.Password)
+	}
+	if cfg.Client != nil {
+		pusher = pusher.Client(cfg.Client)
+	}
+
+	interval := cfg.Interval
+	if interval <= 0 {
+		interval = RefreshInterval
+	}
+
+	return &Pusher{
+		pusher:   pusher,
+		interval: interval,
+		add:      cfg.Add,
+		onError:  cfg.OnError,
+	}
+}
+
+// NewPusher 创建推送 gp 注册表中指标的 Pusher，未设置 OnError 时错误记录到 gp 的日志
+func (gp *Prometheus) NewPusher(cfg *PushConfig) *Pusher {
+	c := *cfg
+	if c.Gatherer == nil {
+		c.Gatherer = gp.gatherer
+	}
+	if c.OnError == nil && gp.log != nil {
+		log := gp.log
+		c.OnError = func(err error) {
+			log.Error("prometheus push err:", zap.Error(err))
+		}
+	}
+	return NewPusher(&c)
+}
+
+// PushOnce 推送一次
+func (p *Pusher) PushOnce(ctx context.Context) error {
+	if p.add {
+		return p.pusher.AddContext(ctx)
+	}
+	return p.pusher.PushContext(ctx)
+}
+
+// Run 按间隔推送直到 ctx 结束，结束时再推送一次，返回最后一次推送的错误
+func (p *Pusher) Run(ctx context.Context) error {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			finalCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), finalPushTimeout)
+			defer cancel()
+			err := p.PushOnce(finalCtx)
+			if err != nil {
+				p.reportError(err)
+			}
+			return err
+		case <-ticker.C:
+			if err := p.PushOnce(ctx); err != nil && ctx.Err() == nil {
+				p.reportError(err)
+			}
+		}
+	}
+}
+
+func (p *Pusher) reportError(err error) {
+	if p.onError != nil {
+		p.onError(err)
+	}
+}
@@ -0,0 +1,192 @@
+package prometheus
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// pushRequest Pushgateway 收到的请求
+type pushRequest struct {
+	method   string
+	path     string
+	username string
+	password string
+	body     string
+}
+
+// pushgatewayStub 模拟 Pushgateway
+type pushgatewayStub struct {
+	mu       sync.Mutex
+	requests []pushRequest
+	status   int
+}
+
+func (s *pushgatewayStub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	username, password, _ := r.BasicAuth()
+
+	s.mu.Lock()
+	s.requests = append(s.requests, pushRequest{
+		method:   r.Method,
+		path:     r.URL.Path,
+		username: username,
+		password: password,
+		body:     string(body),
+	})
+	status := s.status
+	s.mu.Unlock()
+
+	if status == 0 {
+		status = http.StatusOK
+	}
+	w.WriteHeader(status)
+}
+
+func (s *pushgatewayStub) snapshot() []pushRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]pushRequest(nil), s.requests...)
+}
+
+func newTestRegistry() *prometheus.Registry {
+	registry := prometheus.NewRegistry()
+	counter := prometheus.NewCounter(prometheus.CounterOpts{Name: "jobs_total", Help: "test counter"})
+	counter.Add(3)
+	registry.MustRegister(counter)
+	return registry
+}
+
+func TestPusherRun(t *testing.T) {
+	stub := &pushgatewayStub{}
+	server := httptest.NewServer(stub)
+	defer server.Close()
+
+	pusher := NewPusher(&PushConfig{
+		URL:      server.URL,
+		Job:      "batch",
+		Gatherer: newTestRegistry(),
+		Grouping: map[string]string{"instance": "node-1"},
+		Username: "user",
+		Password: "pass",
+		Interval: 10 * time.Millisecond,
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- pusher.Run(ctx)
+	}()
+
+	deadline := time.Now().Add(time.Second)
+	for len(stub.snapshot()) < 2 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	cancel()
+
+	if err := <-done; err != nil {
+		t.Fatalf("Expected final push to succeed, got %v", err)
+	}
+
+	requests := stub.snapshot()
+	if len(requests) < 3 {
+		t.Fatalf("Expected periodic pushes and a final push, got %d requests", len(requests))
+	}
+	for _, req := range requests {
+		if req.method != http.MethodPut {
+			t.Errorf("Expected PUT, got %s", req.method)
+		}
+		if req.path != "/metrics/job/batch/instance/node-1" {
+			t.Errorf("Expected grouping path, got %s", req.path)
+		}
+		if req.username != "user" || req.password != "pass" {
+			t.Errorf("Expected basic auth user:pass, got %s:%s", req.username, req.password)
+		}
+	}
+	if !strings.Contains(requests[0].body, "jobs_total") {
+		t.Error("Expected pushed body to contain registered collectors")
+	}
+}
+
+func TestPusherAdd(t *testing.T) {
+	stub := &pushgatewayStub{}
+	server := httptest.NewServer(stub)
+	defer server.Close()
+
+	pusher := NewPusher(&PushConfig{
+		URL:      server.URL,
+		Job:      "batch",
+		Gatherer: newTestRegistry(),
+		Add:      true,
+	})
+	if err := pusher.PushOnce(context.Background()); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+
+	requests := stub.snapshot()
+	if len(requests) != 1 || requests[0].method != http.MethodPost {
+		t.Errorf("Expected a single POST request, got %+v", requests)
+	}
+}
+
+func TestPusherError(t *testing.T) {
+	stub := &pushgatewayStub{status: http.StatusInternalServerError}
+	server := httptest.NewServer(stub)
+	defer server.Close()
+
+	var (
+		mu   sync.Mutex
+		errs []error
+	)
+	pusher := NewPusher(&PushConfig{
+		URL:      server.URL,
+		Job:      "batch",
+		Gatherer: newTestRegistry(),
+		Interval: 10 * time.Millisecond,
+		OnError: func(err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			errs = append(errs, err)
+		},
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 35*time.Millisecond)
+	defer cancel()
+	if err := pusher.Run(ctx); err == nil {
+		t.Error("Expected final push error to be returned")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(errs) < 2 {
+		t.Errorf("Expected errors to be reported through OnError, got %d", len(errs))
+	}
+}
+
+func TestPrometheusNewPusher(t *testing.T) {
+	stub := &pushgatewayStub{}
+	server := httptest.NewServer(stub)
+	defer server.Close()
+
+	gp := Init(Registry(newTestRegistry()))
+	if err := gp.NewPusher(&PushConfig{URL: server.URL, Job: "app"}).PushOnce(context.Background()); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+
+	requests := stub.snapshot()
+	if len(requests) != 1 {
+		t.Fatalf("Expected 1 request, got %d", len(requests))
+	}
+	for _, name := range []string{"jobs_total", "panic_total"} {
+		if !strings.Contains(requests[0].body, name) {
+			t.Errorf("Expected pushed body to contain %s", name)
+		}
+	}
+}
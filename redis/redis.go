@@ -3,6 +3,7 @@ package redis
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
 
@@ -101,12 +102,33 @@ func (c *RedisCli) Get(key string) (string, error) {
 	return value, nil
 }
 
+// Lookup same as Get, but reports a missing key as ok=false instead of an error
+func (c *RedisCli) Lookup(key string) (string, bool, error) {
+	value, err := c.RedisClient().Get(c.ctx, key).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("redis get key: %s err: %v", key, err)
+	}
+	return value, true, nil
+}
+
 // SetNX
 func (c *RedisCli) SetNX(key, value string, ttl time.Duration) bool {
 	ok, _ := c.RedisClient().SetNX(c.ctx, key, value, ttl).Result()
 	return ok
 }
 
+// SetNXWithError same as SetNX, but returns redis errors instead of treating them as not set
+func (c *RedisCli) SetNXWithError(key, value string, ttl time.Duration) (bool, error) {
+	ok, err := c.RedisClient().SetNX(c.ctx, key, value, ttl).Result()
+	if err != nil {
+		return false, fmt.Errorf("redis setnx key: %s err: %v", key, err)
+	}
+	return ok, nil
+}
+
 // TTL get some key from redis
 func (c *RedisCli) TTL(key string) (time.Duration, error) {
 	ttl, err := c.RedisClient().TTL(c.ctx, key).Result()
@@ -228,6 +250,23 @@ func (c *RedisCli) Hdel(key string, field ...string) int64 {
 	return value
 }
 
+// Eval run lua script
+func (c *RedisCli) Eval(script string, keys []string, args ...interface{}) (interface{}, error) {
+	value, err := c.RedisClient().Eval(c.ctx, script, keys, args...).Result()
+	if err != nil {
+		return nil, fmt.Errorf("redis eval script err: %v", err)
+	}
+	return value, nil
+}
+
+// Ping check redis connection
+func (c *RedisCli) Ping(ctx context.Context) error {
+	if err := c.RedisClient().Ping(ctx).Err(); err != nil {
+		return fmt.Errorf("ping redis err: %v", err)
+	}
+	return nil
+}
+
 // Close close redis client
 func (c *RedisCli) Close() error {
 	if c.clusterClient != nil {
@@ -311,6 +311,28 @@ func (db *DB) Count(ctx context.Context, model interface{}, args ...Args) (int64
 	return count, err
 }
 
+// Ping 检查主库和从库连接是否可用
+func (db *DB) Ping(ctx context.Context) error {
+	sqlDB, err := db.master.DB()
+	if err != nil {
+		return err
+	}
+	if err := sqlDB.PingContext(ctx); err != nil {
+		return fmt.Errorf("ping master failed: %v", err)
+	}
+
+	for i, slave := range db.slaves {
+		sqlDB, err := slave.DB()
+		if err != nil {
+			return err
+		}
+		if err := sqlDB.PingContext(ctx); err != nil {
+			return fmt.Errorf("ping slave %d failed: %v", i, err)
+		}
+	}
+	return nil
+}
+
 // Close 关闭连接
 func (db *DB) Close() error {
 	if db.master != nil {
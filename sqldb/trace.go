@@ -4,7 +4,12 @@ import (
 	"errors"
 	"time"
 
+	"github.com/ffhuo/go-kits/common/field"
 	"github.com/ffhuo/go-kits/logger"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 	"gorm.io/gorm"
 	"gorm.io/gorm/utils"
 )
@@ -12,22 +17,33 @@ import (
 const (
 	callBackBeforeName = "trace:before"
 	callBackAfterName  = "trace:after"
+
+	tracerName = "github.com/ffhuo/go-kits/sqldb"
+	spanKey    = "gorm:trace_span"
 )
 
 // TracePlugin SQL 追踪插件
 type TracePlugin struct {
 	metrics *SQLMetrics
 	logger  logger.ILogger
+	tracer  trace.Tracer
 }
 
-// NewTrace 创建 SQL 追踪插件
+// NewTrace 创建 SQL 追踪插件，span 使用 otel 全局 TracerProvider 创建
 func NewTrace(metrics *SQLMetrics, logger logger.ILogger) *TracePlugin {
 	return &TracePlugin{
 		metrics: metrics,
 		logger:  logger,
+		tracer:  otel.Tracer(tracerName),
 	}
 }
 
+// WithTracerProvider 使用指定的 TracerProvider 创建 span
+func (op *TracePlugin) WithTracerProvider(tp trace.TracerProvider) *TracePlugin {
+	op.tracer = tp.Tracer(tracerName)
+	return op
+}
+
 // Name 插件名称
 func (op *TracePlugin) Name() string {
 	return "tracePlugin"
@@ -36,42 +52,42 @@ func (op *TracePlugin) Name() string {
 // Initialize 初始化插件
 func (op *TracePlugin) Initialize(db *gorm.DB) error {
 	// 注册回调
-	if err := db.Callback().Create().Before("gorm:create").Register(callBackBeforeName, op.before); err != nil {
+	if err := db.Callback().Create().Before("gorm:create").Register(callBackBeforeName, op.before("create")); err != nil {
 		return err
 	}
 	if err := db.Callback().Create().After("gorm:create").Register(callBackAfterName, op.after); err != nil {
 		return err
 	}
 
-	if err := db.Callback().Query().Before("gorm:query").Register(callBackBeforeName, op.before); err != nil {
+	if err := db.Callback().Query().Before("gorm:query").Register(callBackBeforeName, op.before("query")); err != nil {
 		return err
 	}
 	if err := db.Callback().Query().After("gorm:query").Register(callBackAfterName, op.after); err != nil {
 		return err
 	}
 
-	if err := db.Callback().Delete().Before("gorm:delete").Register(callBackBeforeName, op.before); err != nil {
+	if err := db.Callback().Delete().Before("gorm:delete").Register(callBackBeforeName, op.before("delete")); err != nil {
 		return err
 	}
 	if err := db.Callback().Delete().After("gorm:delete").Register(callBackAfterName, op.after); err != nil {
 		return err
 	}
 
-	if err := db.Callback().Update().Before("gorm:update").Register(callBackBeforeName, op.before); err != nil {
+	if err := db.Callback().Update().Before("gorm:update").Register(callBackBeforeName, op.before("update")); err != nil {
 		return err
 	}
 	if err := db.Callback().Update().After("gorm:update").Register(callBackAfterName, op.after); err != nil {
 		return err
 	}
 
-	if err := db.Callback().Row().Before("gorm:row").Register(callBackBeforeName, op.before); err != nil {
+	if err := db.Callback().Row().Before("gorm:row").Register(callBackBeforeName, op.before("row")); err != nil {
 		return err
 	}
 	if err := db.Callback().Row().After("gorm:row").Register(callBackAfterName, op.after); err != nil {
 		return err
 	}
 
-	if err := db.Callback().Raw().Before("gorm:raw").Register(callBackBeforeName, op.before); err != nil {
+	if err := db.Callback().Raw().Before("gorm:raw").Register(callBackBeforeName, op.before("raw")); err != nil {
 		return err
 	}
 	if err := db.Callback().Raw().After("gorm:raw").Register(callBackAfterName, op.after); err != nil {
@@ -81,8 +97,46 @@ func (op *TracePlugin) Initialize(db *gorm.DB) error {
 	return nil
 }
 
-func (op *TracePlugin) before(db *gorm.DB) {
-	db.InstanceSet("gorm:trace_start_time", time.Now())
+func (op *TracePlugin) before(operation string) func(db *gorm.DB) {
+	return func(db *gorm.DB) {
+		db.InstanceSet("gorm:trace_start_time", time.Now())
+
+		if db.Statement.Context == nil || op.tracer == nil {
+			return
+		}
+		ctx, span := op.tracer.Start(db.Statement.Context, "gorm."+operation,
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(
+				attribute.String("db.system", db.Dialector.Name()),
+				attribute.String("db.operation.name", operation),
+			),
+		)
+		db.Statement.Context = ctx
+		db.InstanceSet(spanKey, span)
+	}
+}
+
+// endSpan 结束 before 中创建的 span，db.statement 使用带占位符的 SQL，避免参数值随 trace 导出
+func (op *TracePlugin) endSpan(db *gorm.DB, rows int64) {
+	_span, ok := db.InstanceGet(spanKey)
+	if !ok {
+		return
+	}
+	span, ok := _span.(trace.Span)
+	if !ok {
+		return
+	}
+
+	span.SetAttributes(
+		attribute.String("db.statement", db.Statement.SQL.String()),
+		attribute.String("db.collection.name", db.Statement.Table),
+		attribute.Int64("db.rows_affected", rows),
+	)
+	if db.Error != nil && !errors.Is(db.Error, gorm.ErrRecordNotFound) {
+		span.RecordError(db.Error)
+		span.SetStatus(codes.Error, db.Error.Error())
+	}
+	span.End()
 }
 
 func (op *TracePlugin) after(db *gorm.DB) {
@@ -109,6 +163,7 @@ func (op *TracePlugin) after(db *gorm.DB) {
 		Timestamp:   ts,
 	}
 	op.metrics.Collect(trace)
+	op.endSpan(db, trace.Rows)
 
 	// 记录日志
 	fields := []interface{}{
@@ -117,12 +172,24 @@ func (op *TracePlugin) after(db *gorm.DB) {
 		"duration", time.Duration(trace.CostSeconds * float64(time.Second)),
 	}
 
+	ctx := db.Statement.Context
+	if sc := traceSpanContext(db); sc.IsValid() {
+		ctx = field.With(ctx, field.F("trace_id", sc.TraceID().String()), field.F("span_id", sc.SpanID().String()))
+	}
+
 	if db.Error != nil && !errors.Is(db.Error, gorm.ErrRecordNotFound) {
 		fields = append(fields, "error", db.Error)
-		op.logger.Error(db.Statement.Context, "SQL执行出错", fields...)
+		op.logger.Error(ctx, "SQL执行出错", fields...)
 	} else {
-		op.logger.Debug(db.Statement.Context, "SQL执行成功", fields...)
+		op.logger.Debug(ctx, "SQL执行成功", fields...)
+	}
+}
+
+func traceSpanContext(db *gorm.DB) trace.SpanContext {
+	if db.Statement.Context == nil {
+		return trace.SpanContext{}
 	}
+	return trace.SpanContextFromContext(db.Statement.Context)
 }
 
 // SQLTrace SQL 执行跟踪信息